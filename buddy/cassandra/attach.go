@@ -0,0 +1,191 @@
+package cassandra
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// daemonClass is the main class on the command line of a cassandra jvm.
+const daemonClass = "org.apache.cassandra.service.CassandraDaemon"
+
+// Attach returns a Process for a cassandra managed outside of buddy, by systemd
+// or kubernetes for example. The node is found by cfg.PidFile, by scanning /proc
+// for the cassandra daemon or by its JMX port, in that order. Start and Stop run
+// the StartCommand and StopCommand hooks.
+func Attach(cfg *Config) Process {
+	return &attachedProcess{
+		cfg: cfg,
+	}
+}
+
+type attachedProcess struct {
+	cfg *Config
+}
+
+func (a *attachedProcess) Start() error {
+	if a.Running() {
+		return nil
+	}
+	if a.cfg.StartCommand == "" {
+		return errors.New("Cassandra is not running and no start command is configured")
+	}
+	if err := a.cfg.render(); err != nil {
+		return err
+	}
+	return runHook(a.cfg.StartCommand)
+}
+
+func (a *attachedProcess) Stop() error {
+	if !a.Running() {
+		return nil
+	}
+	if a.cfg.StopCommand == "" {
+		return errors.New("Cassandra is managed externally and no stop command is configured")
+	}
+	if err := runHook(a.cfg.StopCommand); err != nil {
+		return err
+	}
+	if a.waitStopped(a.cfg.StopTimeout) {
+		return nil
+	}
+	pid, err := FindPid(a.cfg)
+	if err != nil {
+		return fmt.Errorf("Cassandra still running %s after stop command", a.cfg.StopTimeout)
+	}
+	log.Println("Cassandra did not exit in", a.cfg.StopTimeout, "sending SIGKILL to", pid)
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+		return err
+	}
+	if !a.waitStopped(30 * time.Second) {
+		return fmt.Errorf("Cassandra %d still running after SIGKILL", pid)
+	}
+	return nil
+}
+
+func (a *attachedProcess) waitStopped(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for a.Running() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(1 * time.Second)
+	}
+	return true
+}
+
+func (a *attachedProcess) Running() bool {
+	if pid, err := FindPid(a.cfg); err == nil {
+		return pidAlive(pid)
+	}
+	return jmxListening(a.cfg.JmxPort)
+}
+
+// State reports the pid and whether the node runs, restarts are up to the
+// external process manager and not tracked.
+func (a *attachedProcess) State() *ProcessState {
+	state := &ProcessState{Running: a.Running()}
+	if pid, err := FindPid(a.cfg); err == nil && state.Running {
+		state.Pid = pid
+	}
+	return state
+}
+
+// SetReplaceAddress only reaches an attached node through cfg.JVMOptionsFile.
+func (a *attachedProcess) SetReplaceAddress(addr string) error {
+	if a.cfg.JVMOptionsFile == "" && addr != "" {
+		return errors.New("Replacing a node needs a jvm options file when cassandra is attached")
+	}
+	return a.cfg.setReplaceAddress(addr)
+}
+
+func (a *attachedProcess) Output() *Output {
+	return nil
+}
+
+func (a *attachedProcess) ClearData(keyspaces []string) error {
+	if a.Running() {
+		return errors.New("Cassandra must be stopped before clearing data")
+	}
+	return clearData(a.cfg.DataPaths, keyspaces)
+}
+
+func (a *attachedProcess) ClearLogs() error {
+	if a.Running() {
+		return errors.New("Cassandra must be stopped before clearing commit logs")
+	}
+	return clearLogs(a.cfg)
+}
+
+// FindPid returns the pid of the running cassandra from cfg.PidFile or, without a
+// pid file, from the process table.
+func FindPid(cfg *Config) (int, error) {
+	if cfg.PidFile != "" {
+		d, err := ioutil.ReadFile(cfg.PidFile)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(strings.TrimSpace(string(d)))
+	}
+	return scanProcs("/proc")
+}
+
+// scanProcs looks for a cassandra daemon in a proc filesystem.
+func scanProcs(proc string) (int, error) {
+	dirs, err := ioutil.ReadDir(proc)
+	if err != nil {
+		return 0, err
+	}
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(dir.Name())
+		if err != nil || !dir.IsDir() {
+			continue
+		}
+		cmdline, err := ioutil.ReadFile(filepath.Join(proc, dir.Name(), "cmdline"))
+		if err != nil {
+			continue
+		}
+		for _, arg := range strings.Split(string(cmdline), "\x00") {
+			if arg == daemonClass {
+				return pid, nil
+			}
+		}
+	}
+	return 0, errors.New("No cassandra process found")
+}
+
+func pidAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+func jmxListening(port int) bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), 2*time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// runHook runs a lifecycle hook command like "systemctl stop cassandra" with sh.
+func runHook(command string) error {
+	log.Println("Running", command)
+	out, err := exec.Command("/bin/sh", "-c", command).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %v: %s", command, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package cassandra
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanProcs(t *testing.T) {
+	proc, err := ioutil.TempDir("", "buddy-proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(proc)
+
+	cmdlines := map[string]string{
+		"1":    "/sbin/init\x00",
+		"212":  "java\x00-Xmx2G\x00-cp\x00/usr/share/cassandra/*\x00" + daemonClass + "\x00",
+		"self": "ignored\x00",
+	}
+	for pid, cmdline := range cmdlines {
+		if err := os.MkdirAll(filepath.Join(proc, pid), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(proc, pid, "cmdline"), []byte(cmdline), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pid, err := scanProcs(proc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid != 212 {
+		t.Fatalf("expected pid 212, got %d", pid)
+	}
+}
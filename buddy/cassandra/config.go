@@ -1,49 +1,114 @@
 package cassandra
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	Executable   string
 	JoinRing     bool
 	NewHeapSize  string
 	HeapSize     string
-	DataPath     string
+	DataPaths    []string
 	CommitPath   string
 	BackupPath   string
 	CachePath    string
+	HintsPath    string
 	JmxPort      int
+	NativePort   int
 	MaxDirectMem string
+	// ReplaceAddress starts the node as a replacement for a dead node with
+	// that address.
+	ReplaceAddress string
+	// JVM options are passed in JVM_EXTRA_OPTS, or written into JVMOptionsFile
+	// when it is set. An attached node only gets them through the file.
+	JVM            JVMOptions
+	JVMOptionsFile string
+
+	// cassandra.yaml is rendered from YamlTemplate into YamlPath before start
+	// when a template is set, the settings below override the template.
+	YamlTemplate       string
+	YamlPath           string
+	ClusterName        string
+	Seeds              []string
+	ListenAddress      string
+	RPCAddress         string
+	Snitch             string
+	IncrementalBackups bool
+
+	// A crashed node is restarted after RestartBackoff, doubled for each
+	// consecutive crash up to MaxRestartBackoff, at most MaxRestarts times.
+	MaxRestarts       int
+	RestartBackoff    time.Duration
+	MaxRestartBackoff time.Duration
+
+	// stdout and stderr are written to LogFile, rotated at LogMaxSize bytes
+	// keeping LogMaxFiles files, the last LogLines lines are kept in memory.
+	LogFile     string
+	LogMaxSize  int64
+	LogMaxFiles int
+	LogLines    int
+
+	// StopTimeout is how long Stop waits for cassandra to exit before it is
+	// killed.
+	StopTimeout time.Duration
+
+	// Attached nodes are managed outside of buddy, see Attach. PidFile is
+	// optional, the hooks are run with sh to start and stop the node.
+	PidFile      string
+	StartCommand string
+	StopCommand  string
 }
 
-func (c *Config) Env() []string {
+func (c *Config) Env() ([]string, error) {
 	e := make([]string, 0)
 	e = append(e, fmt.Sprintf("HEAP_NEWSIZE=%s", c.NewHeapSize))
 	e = append(e, fmt.Sprintf("MAX_HEAP_SIZE=%s", c.HeapSize))
-	e = append(e, fmt.Sprintf("DATA_DIR=%s", c.DataPath))
+	if len(c.DataPaths) > 0 {
+		e = append(e, fmt.Sprintf("DATA_DIR=%s", c.DataPaths[0]))
+	}
 	e = append(e, fmt.Sprintf("COMMIT_LOG_DIR=%s", c.CommitPath))
 	e = append(e, fmt.Sprintf("LOCAL_BACKUP_DIR=%s", c.BackupPath))
 	e = append(e, fmt.Sprintf("CACHE_DIR=%s", c.CachePath))
 	e = append(e, fmt.Sprintf("JMX_PORT=%d", c.JmxPort))
 	e = append(e, fmt.Sprintf("MAX_DIRECT_MEMORY=%s", c.MaxDirectMem))
-	jr := "false"
-	if c.JoinRing {
-		jr = "true"
+	// with a jvm.options file the options are written there by render
+	if c.JVMOptionsFile == "" {
+		args, err := c.JVMArgs()
+		if err != nil {
+			return nil, err
+		}
+		e = append(e, fmt.Sprintf("JVM_EXTRA_OPTS=%s", strings.Join(args, " ")))
 	}
-	e = append(e, fmt.Sprintf("cassandra.join_ring=%s", jr))
-	return e
+	return e, nil
 }
 
 func DefaultConfig() *Config {
 	return &Config{
 		Executable:   "/usr/local/bin/cassandra",
-		JoinRing:     false,
+		JoinRing:     true,
 		HeapSize:     "2G",
 		NewHeapSize:  "200M",
-		DataPath:     "/usr/local/var/lib/cassandra/data",
+		DataPaths:    []string{"/usr/local/var/lib/cassandra/data"},
 		CommitPath:   "/usr/local/var/lib/cassandra/commitlog",
 		BackupPath:   "/usr/local/var/lib/cassandra/backups",
 		CachePath:    "/usr/local/var/lib/cassandra/cache",
+		HintsPath:    "/usr/local/var/lib/cassandra/hints",
 		JmxPort:      7199,
+		NativePort:   9042,
 		MaxDirectMem: "1G",
+		YamlPath:     "/usr/local/var/lib/cassandra/buddy/cassandra.yaml",
+		StopTimeout:  2 * time.Minute,
+
+		MaxRestarts:       5,
+		RestartBackoff:    5 * time.Second,
+		MaxRestartBackoff: 5 * time.Minute,
+
+		LogFile:     "/usr/local/var/log/cassandra/output.log",
+		LogMaxSize:  100 * 1024 * 1024,
+		LogMaxFiles: 5,
+		LogLines:    1000,
 	}
 }
@@ -0,0 +1,87 @@
+package cassandra
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Volume is the filesystem one or more data directories are on.
+type Volume struct {
+	Paths []string
+	// Available is the space unprivileged processes may still use, in bytes.
+	Available int64
+}
+
+// Volumes groups paths by the filesystem they are on.
+func Volumes(paths []string) ([]*Volume, error) {
+	volumes := make([]*Volume, 0)
+	byDev := make(map[uint64]*Volume)
+	for _, path := range paths {
+		var st syscall.Stat_t
+		if err := syscall.Stat(path, &st); err != nil {
+			return nil, &os.PathError{Op: "stat", Path: path, Err: err}
+		}
+		if v, ok := byDev[uint64(st.Dev)]; ok {
+			v.Paths = append(v.Paths, path)
+			continue
+		}
+		var fs syscall.Statfs_t
+		if err := syscall.Statfs(path, &fs); err != nil {
+			return nil, &os.PathError{Op: "statfs", Path: path, Err: err}
+		}
+		v := &Volume{Paths: []string{path}, Available: int64(fs.Bavail) * int64(fs.Bsize)}
+		byDev[uint64(st.Dev)] = v
+		volumes = append(volumes, v)
+	}
+	return volumes, nil
+}
+
+// DataSize sums the files of keyspaces in one data directory, all keyspaces if
+// none are given. Hardlinked files are counted once. Snapshots and incremental
+// backups are only counted with snapshots, which makes it the space ClearData
+// frees.
+func DataSize(dataPath string, keyspaces []string, snapshots bool) (int64, error) {
+	if len(keyspaces) == 0 {
+		ksdir, err := ioutil.ReadDir(dataPath)
+		if err != nil {
+			return 0, err
+		}
+		for _, ks := range ksdir {
+			if ks.IsDir() {
+				keyspaces = append(keyspaces, ks.Name())
+			}
+		}
+	}
+	var size int64
+	seen := make(map[uint64]bool)
+	for _, ks := range keyspaces {
+		err := filepath.Walk(filepath.Join(dataPath, ks), func(path string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) {
+				// a keyspace without data on this disk yet
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if !snapshots && (info.Name() == "snapshots" || info.Name() == "backups") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if st, ok := info.Sys().(*syscall.Stat_t); ok {
+				if seen[uint64(st.Ino)] {
+					return nil
+				}
+				seen[uint64(st.Ino)] = true
+			}
+			size += info.Size()
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
@@ -0,0 +1,66 @@
+package cassandra
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDataSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassandra-disk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	table := filepath.Join(dir, "app", "events-5b1a7e20f6a611e5a8a4c1d3a4c7e9a1")
+	snapshot := filepath.Join(table, "snapshots", "1460628403086")
+	if err := os.MkdirAll(snapshot, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := filepath.Join(table, "mc-1-big-Data.db")
+	if err := ioutil.WriteFile(data, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// a snapshot of a compacted away sstable and a hardlink of a live one
+	if err := ioutil.WriteFile(filepath.Join(snapshot, "mc-0-big-Data.db"), make([]byte, 50), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(data, filepath.Join(snapshot, "mc-1-big-Data.db")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "other", "users-5b1a7e20f6a611e5a8a4c1d3a4c7e9a1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "other", "users-5b1a7e20f6a611e5a8a4c1d3a4c7e9a1", "mc-1-big-Data.db"), make([]byte, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		keyspaces []string
+		snapshots bool
+		size      int64
+	}{
+		{nil, false, 110},
+		{nil, true, 160},
+		{[]string{"app"}, true, 150},
+		{[]string{"app", "missing"}, false, 100},
+	}
+	for _, c := range cases {
+		size, err := DataSize(dir, c.keyspaces, c.snapshots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != c.size {
+			t.Errorf("DataSize(%v, %v) = %d, want %d", c.keyspaces, c.snapshots, size, c.size)
+		}
+	}
+
+	volumes, err := Volumes([]string{dir, table})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 1 || len(volumes[0].Paths) != 2 || volumes[0].Available <= 0 {
+		t.Fatalf("unexpected volumes %+v", volumes)
+	}
+}
@@ -0,0 +1,142 @@
+package cassandra
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// JVMOptions are passed to the cassandra jvm on top of what cassandra-env.sh or
+// jvm.options already set.
+type JVMOptions struct {
+	// Properties are set with -Dkey=value.
+	Properties map[string]string
+	// GC selects the collector, G1 or CMS. The distribution default has to be
+	// removed from jvm.options or cassandra-env.sh, the jvm refuses two collectors.
+	GC string
+	// GCLogFile enables rotated gc logging into the file.
+	GCLogFile string
+	// Flags are passed as is, for example -XX:+HeapDumpOnOutOfMemoryError.
+	Flags []string
+}
+
+// jvmOptionsBegin and jvmOptionsEnd mark the options buddy manages in a
+// jvm.options file.
+const (
+	jvmOptionsBegin = "### cassandra-buddy begin, managed by buddy"
+	jvmOptionsEnd   = "### cassandra-buddy end"
+)
+
+// JVMArgs returns the jvm arguments for cfg.
+func (c *Config) JVMArgs() ([]string, error) {
+	props := map[string]string{
+		"cassandra.join_ring": fmt.Sprint(c.JoinRing),
+	}
+	if c.YamlTemplate != "" {
+		props["cassandra.config"] = "file://" + c.YamlPath
+	}
+	if c.ReplaceAddress != "" {
+		props["cassandra.replace_address_first_boot"] = c.ReplaceAddress
+	}
+	for k, v := range c.JVM.Properties {
+		props[k] = v
+	}
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	args := make([]string, 0)
+	for _, k := range keys {
+		args = append(args, fmt.Sprintf("-D%s=%s", k, props[k]))
+	}
+	switch strings.ToUpper(c.JVM.GC) {
+	case "":
+	case "G1":
+		args = append(args, "-XX:+UseG1GC")
+	case "CMS":
+		args = append(args, "-XX:+UseParNewGC", "-XX:+UseConcMarkSweepGC", "-XX:+CMSParallelRemarkEnabled")
+	default:
+		return nil, fmt.Errorf("Unknown garbage collector %s, use G1 or CMS", c.JVM.GC)
+	}
+	if c.JVM.GCLogFile != "" {
+		args = append(args,
+			"-Xloggc:"+c.JVM.GCLogFile,
+			"-XX:+PrintGCDetails",
+			"-XX:+PrintGCDateStamps",
+			"-XX:+UseGCLogFileRotation",
+			"-XX:NumberOfGCLogFiles=10",
+			"-XX:GCLogFileSize=10M")
+	}
+	args = append(args, c.JVM.Flags...)
+	return args, nil
+}
+
+// WriteJVMOptions replaces the options buddy manages in the jvm.options file at
+// path, the rest of the file is left alone.
+func WriteJVMOptions(path string, args []string) error {
+	d, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lines := make([]string, 0)
+	managed := false
+	for _, line := range strings.Split(strings.TrimRight(string(d), "\n"), "\n") {
+		switch {
+		case line == jvmOptionsBegin:
+			managed = true
+		case line == jvmOptionsEnd:
+			managed = false
+		case !managed && (line != "" || len(lines) > 0):
+			lines = append(lines, line)
+		}
+	}
+	lines = append(lines, jvmOptionsBegin)
+	lines = append(lines, args...)
+	lines = append(lines, jvmOptionsEnd)
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// render writes the files cassandra reads its configuration from before it is
+// started.
+func (c *Config) render() error {
+	if c.YamlTemplate != "" {
+		if err := RenderYaml(c); err != nil {
+			return err
+		}
+	}
+	if c.JVMOptionsFile != "" {
+		args, err := c.JVMArgs()
+		if err != nil {
+			return err
+		}
+		return WriteJVMOptions(c.JVMOptionsFile, args)
+	}
+	return nil
+}
+
+// setReplaceAddress sets the address of the node to replace on the next start,
+// an empty address clears it. A jvm.options file is rewritten right away so an
+// external restart does not replace the node again.
+func (c *Config) setReplaceAddress(addr string) error {
+	c.ReplaceAddress = addr
+	if c.JVMOptionsFile == "" {
+		return nil
+	}
+	args, err := c.JVMArgs()
+	if err != nil {
+		return err
+	}
+	return WriteJVMOptions(c.JVMOptionsFile, args)
+}
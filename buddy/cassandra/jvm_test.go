@@ -0,0 +1,67 @@
+package cassandra
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJVMArgs(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.YamlTemplate = "/etc/cassandra/cassandra.yaml.tmpl"
+	cfg.ReplaceAddress = "10.0.0.4"
+	cfg.JVM = JVMOptions{
+		Properties: map[string]string{"cassandra.consistent.rangemovement": "false"},
+		GC:         "g1",
+		Flags:      []string{"-XX:+HeapDumpOnOutOfMemoryError"},
+	}
+	args, err := cfg.JVMArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"-Dcassandra.config=file://" + cfg.YamlPath,
+		"-Dcassandra.consistent.rangemovement=false",
+		"-Dcassandra.join_ring=true",
+		"-Dcassandra.replace_address_first_boot=10.0.0.4",
+		"-XX:+UseG1GC",
+		"-XX:+HeapDumpOnOutOfMemoryError",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("unexpected args %q", args)
+	}
+
+	cfg.JVM.GC = "serial"
+	if _, err := cfg.JVMArgs(); err == nil {
+		t.Fatal("expected an unknown collector to fail")
+	}
+}
+
+func TestWriteJVMOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buddy-jvm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jvm.options")
+	if err := ioutil.WriteFile(path, []byte("-ea\n-Xss256k\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteJVMOptions(path, []string{"-Dcassandra.join_ring=false"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteJVMOptions(path, []string{"-Dcassandra.join_ring=true"}); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{"-ea", "-Xss256k", jvmOptionsBegin, "-Dcassandra.join_ring=true", jvmOptionsEnd}, "\n") + "\n"
+	if string(d) != want {
+		t.Fatalf("unexpected jvm.options:\n%s", d)
+	}
+}
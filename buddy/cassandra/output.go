@@ -0,0 +1,221 @@
+package cassandra
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// maxLineLength caps a line kept in memory, longer output without a newline
+// is split into several lines.
+const maxLineLength = 64 * 1024
+
+// Output collects cassandra stdout and stderr. Everything is written to a log
+// file rotated at maxSize and the most recent lines are kept in memory for the
+// logs api.
+type Output struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+	// lines is a ring buffer, next is where the next line goes
+	lines   []string
+	next    int
+	full    bool
+	partial []byte
+	subs    map[chan string]bool
+}
+
+// NewOutput returns an Output writing to path, without a path output is only
+// kept in memory.
+func NewOutput(path string, maxSize int64, maxFiles, lines int) *Output {
+	if lines <= 0 {
+		lines = 1
+	}
+	return &Output{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		lines:    make([]string, lines),
+		subs:     make(map[chan string]bool),
+	}
+}
+
+// Write implements io.Writer, the process writes stdout and stderr here.
+func (o *Output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := o.writeFile(p); err != nil {
+		// losing the file must not block cassandra, the lines are still kept
+		log.Println("Failed to write cassandra output", err)
+	}
+	data := append(o.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		o.addLine(string(bytes.TrimRight(data[:i], "\r")))
+		data = data[i+1:]
+	}
+	for len(data) > maxLineLength {
+		o.addLine(string(data[:maxLineLength]))
+		data = data[maxLineLength:]
+	}
+	o.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+func (o *Output) addLine(line string) {
+	o.lines[o.next] = line
+	o.next = (o.next + 1) % len(o.lines)
+	if o.next == 0 {
+		o.full = true
+	}
+	for ch := range o.subs {
+		select {
+		case ch <- line:
+		default:
+			// a follower that can not keep up misses lines rather than
+			// blocking cassandra output
+		}
+	}
+}
+
+// Tail returns the last n lines, all kept lines when n <= 0.
+func (o *Output) Tail(n int) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.tail(n)
+}
+
+func (o *Output) tail(n int) []string {
+	count := o.next
+	if o.full {
+		count = len(o.lines)
+	}
+	if n <= 0 || n > count {
+		n = count
+	}
+	lines := make([]string, 0, n)
+	for i := count - n; i < count; i++ {
+		idx := i
+		if o.full {
+			idx = (o.next + i) % len(o.lines)
+		}
+		lines = append(lines, o.lines[idx])
+	}
+	return lines
+}
+
+// Follow returns the last n lines and a channel of the lines written after
+// them, cancel stops the channel.
+func (o *Output) Follow(n int) ([]string, <-chan string, func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ch := make(chan string, 256)
+	o.subs[ch] = true
+	cancel := func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if o.subs[ch] {
+			delete(o.subs, ch)
+			close(ch)
+		}
+	}
+	return o.tail(n), ch, cancel
+}
+
+// CloseFollowers closes the channels of all followers, the output of a process
+// that is replaced is not written to again.
+func (o *Output) CloseFollowers() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for ch := range o.subs {
+		delete(o.subs, ch)
+		close(ch)
+	}
+}
+
+// Close closes the log file.
+func (o *Output) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file == nil {
+		return nil
+	}
+	err := o.file.Close()
+	o.file = nil
+	return err
+}
+
+func (o *Output) writeFile(p []byte) error {
+	if o.path == "" {
+		return nil
+	}
+	if o.file == nil {
+		if err := o.open(); err != nil {
+			return err
+		}
+	}
+	if o.maxSize > 0 && o.size+int64(len(p)) > o.maxSize && o.size > 0 {
+		if err := o.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := o.file.Write(p)
+	o.size += int64(n)
+	return err
+}
+
+func (o *Output) open() error {
+	if err := os.MkdirAll(filepath.Dir(o.path), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(o.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	o.file = f
+	o.size = stat.Size()
+	return nil
+}
+
+// rotate moves path to path.1, path.1 to path.2 and so on, dropping the oldest
+// of maxFiles files.
+func (o *Output) rotate() error {
+	o.file.Close()
+	o.file = nil
+	for i := o.maxFiles - 1; i > 0; i-- {
+		src := o.rotated(i - 1)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(src, o.rotated(i)); err != nil {
+			return err
+		}
+	}
+	if o.maxFiles <= 1 {
+		if err := os.Remove(o.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return o.open()
+}
+
+func (o *Output) rotated(i int) string {
+	if i == 0 {
+		return o.path
+	}
+	return fmt.Sprintf("%s.%d", o.path, i)
+}
@@ -0,0 +1,67 @@
+package cassandra
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOutputTail(t *testing.T) {
+	o := NewOutput("", 0, 0, 3)
+	o.Write([]byte("one\ntw"))
+	o.Write([]byte("o\r\nthree\n"))
+	if got := o.Tail(0); !reflect.DeepEqual(got, []string{"one", "two", "three"}) {
+		t.Fatalf("unexpected lines %q", got)
+	}
+	lines, follow, cancel := o.Follow(1)
+	defer cancel()
+	if !reflect.DeepEqual(lines, []string{"three"}) {
+		t.Fatalf("unexpected follow tail %q", lines)
+	}
+	o.Write([]byte("four\n"))
+	if line := <-follow; line != "four" {
+		t.Fatalf("unexpected followed line %q", line)
+	}
+	if got := o.Tail(2); !reflect.DeepEqual(got, []string{"three", "four"}) {
+		t.Fatalf("unexpected lines after wrap %q", got)
+	}
+	o.CloseFollowers()
+	if _, ok := <-follow; ok {
+		t.Fatal("follower was not closed")
+	}
+}
+
+func TestOutputLongLine(t *testing.T) {
+	o := NewOutput("", 0, 0, 3)
+	o.Write(make([]byte, maxLineLength+10))
+	if got := o.Tail(0); len(got) != 1 || len(got[0]) != maxLineLength || len(o.partial) != 10 {
+		t.Fatalf("long line was not split, %d lines and %d bytes pending", len(got), len(o.partial))
+	}
+}
+
+func TestOutputRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buddy-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassandra.out")
+	o := NewOutput(path, 10, 2, 10)
+	defer o.Close()
+	for _, line := range []string{"aaaaaaa\n", "bbbbbbb\n", "ccccccc\n"} {
+		o.Write([]byte(line))
+	}
+	d, err := ioutil.ReadFile(path)
+	if err != nil || string(d) != "ccccccc\n" {
+		t.Fatalf("unexpected current log %q %v", d, err)
+	}
+	d, err = ioutil.ReadFile(path + ".1")
+	if err != nil || string(d) != "bbbbbbb\n" {
+		t.Fatalf("unexpected rotated log %q %v", d, err)
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Fatal("more than maxFiles logs kept")
+	}
+}
@@ -1,50 +1,115 @@
 package cassandra
 
 import (
-	"bytes"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sync"
 	"syscall"
+	"time"
 )
 
 type Process interface {
 	Start() error
 	Stop() error
 	Running() bool
+	State() *ProcessState
+	// SetReplaceAddress makes the next start replace the dead node at addr,
+	// an empty addr clears it.
+	SetReplaceAddress(addr string) error
+	// Output is the captured stdout and stderr, nil when cassandra is not run by buddy.
+	Output() *Output
 	ClearData(keyspaces []string) error
 	ClearLogs() error
 }
 
+// New returns a Process that runs cassandra as a child of buddy. The child is
+// supervised and restarted with a backoff when it exits without Stop.
 func New(cfg *Config) Process {
 	return &cassandraProcess{
-		cfg: cfg,
+		cfg:    cfg,
+		output: NewOutput(cfg.LogFile, cfg.LogMaxSize, cfg.LogMaxFiles, cfg.LogLines),
 	}
 }
 
 type cassandraProcess struct {
 	cfg    *Config
+	mu     sync.Mutex
 	cmd    *exec.Cmd
-	stdout *bytes.Buffer
+	output *Output
+	// exited is closed when cmd exits
+	exited    chan struct{}
+	startedAt time.Time
+	stopping  bool
+	// runs counts Start calls, a pending restart is dropped when Start is
+	// called again while it waits.
+	runs     int
+	restarts int
+	failures int
+	lastExit *Exit
 }
 
 func (c *cassandraProcess) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running() {
+		return nil
+	}
+	c.stopping = false
+	c.failures = 0
+	c.runs++
+	return c.start()
+}
+
+// start runs cassandra and its supervisor, c.mu must be held.
+func (c *cassandraProcess) start() error {
+	if err := c.cfg.render(); err != nil {
+		return err
+	}
+	env, err := c.cfg.Env()
+	if err != nil {
+		return err
+	}
 	cmd := exec.Command(c.cfg.Executable, "-f")
-	cmd.Env = c.buildEnv()
+	cmd.Env = append(os.Environ(), env...)
 
+	cmd.Stdout = c.output
+	cmd.Stderr = c.output
+	if err := cmd.Start(); err != nil {
+		return err
+	}
 	c.cmd = cmd
-	c.stdout = new(bytes.Buffer)
-	c.cmd.Stdout = c.stdout
-	err := c.cmd.Start()
-	return err
+	c.startedAt = time.Now()
+	c.exited = make(chan struct{})
+	go c.supervise(cmd, c.exited, c.runs)
+	return nil
 }
 
 func (c *cassandraProcess) ClearData(keyspaces []string) error {
-	// if keyspaces are not provided, remove everything.
+	if c.Running() {
+		return errors.New("Cassandra must be stopped before clearing data")
+	}
+	return clearData(c.cfg.DataPaths, keyspaces)
+}
+
+func clearData(dataPaths, keyspaces []string) error {
+	for _, dataPath := range dataPaths {
+		if err := clearDataDir(dataPath, keyspaces); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearDataDir empties the table directories of keyspaces in one data directory,
+// if keyspaces are not provided, everything is removed.
+func clearDataDir(dataPath string, keyspaces []string) error {
 	if len(keyspaces) == 0 {
-		ksdir, err := ioutil.ReadDir(c.cfg.DataPath)
+		ksdir, err := ioutil.ReadDir(dataPath)
 		if err != nil {
 			return err
 		}
@@ -55,13 +120,20 @@ func (c *cassandraProcess) ClearData(keyspaces []string) error {
 		}
 	}
 	for _, ks := range keyspaces {
-		files, err := ioutil.ReadDir(filepath.Join(c.cfg.DataPath, ks))
-		if err != nil {
+		tables, err := ioutil.ReadDir(filepath.Join(dataPath, ks))
+		if os.IsNotExist(err) {
+			// a keyspace without data on this disk yet
+			continue
+		} else if err != nil {
 			return err
 		}
-		for _, f := range files {
-			log.Println("Removing", filepath.Join(c.cfg.DataPath, ks, f.Name()))
-			if err := os.RemoveAll(filepath.Join(c.cfg.DataPath, ks, f.Name())); err != nil {
+		// table directories are kept, their names carry the table ids restore
+		// needs to place downloaded files.
+		for _, table := range tables {
+			if !table.IsDir() {
+				continue
+			}
+			if err := clearDir(filepath.Join(dataPath, ks, table.Name())); err != nil {
 				return err
 			}
 		}
@@ -69,49 +141,149 @@ func (c *cassandraProcess) ClearData(keyspaces []string) error {
 	return nil
 }
 
-func (c *cassandraProcess) ClearLogs() error {
+// clearDir removes everything under dir but leaves dir itself in place.
+func clearDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		log.Println("Removing", filepath.Join(dir, f.Name()))
+		if err := os.RemoveAll(filepath.Join(dir, f.Name())); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (c *cassandraProcess) Stop() error {
-	if !c.Running() {
-		return nil
+// ClearLogs removes commit log segments, saved caches and hints. Replaying a
+// commit log written before a restore would bring back data the restore removed.
+func (c *cassandraProcess) ClearLogs() error {
+	if c.Running() {
+		return errors.New("Cassandra must be stopped before clearing commit logs")
 	}
-	log.Println(c.cmd.Process)
-	log.Println(c.cmd.ProcessState)
-	log.Println("Sending SIGTERM to", c.cmd.Process.Pid)
+	return clearLogs(c.cfg)
+}
 
-	c.cmd.Process.Signal(syscall.SIGTERM)
+func clearLogs(cfg *Config) error {
+	for _, dir := range []string{cfg.CommitPath, cfg.CachePath, cfg.HintsPath} {
+		if dir == "" {
+			continue
+		}
+		if err := clearDir(dir); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
 
-	if err := c.cmd.Wait(); err != nil {
-		if execErr, ok := err.(*exec.ExitError); ok {
-			if status, ok := execErr.Sys().(syscall.WaitStatus); ok {
-				// 128 + SIGTERM = 143
-				if status.ExitStatus() == 143 {
-					return nil
-				}
+// errHasState stops the walk of HasState at the first file.
+var errHasState = errors.New("has state")
+
+// HasState reports whether the data, commit log or saved caches directories of
+// cfg hold any files. Cassandra ignores the replace address of a node that was
+// bootstrapped before and comes back with its own identity.
+func HasState(cfg *Config) (bool, error) {
+	dirs := append([]string{cfg.CommitPath, cfg.CachePath}, cfg.DataPaths...)
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return errHasState
 			}
+			return nil
+		})
+		if err == errHasState {
+			return true, nil
+		} else if err != nil && !os.IsNotExist(err) {
+			return false, err
 		}
+	}
+	return false, nil
+}
+
+func (c *cassandraProcess) Stop() error {
+	c.mu.Lock()
+	c.stopping = true
+	if !c.running() {
+		c.mu.Unlock()
+		return nil
+	}
+	cmd, exited := c.cmd, c.exited
+	c.mu.Unlock()
+
+	log.Println("Sending SIGTERM to", cmd.Process.Pid)
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
 		return err
 	}
+	killed := false
+	select {
+	case <-exited:
+	case <-time.After(c.cfg.StopTimeout):
+		log.Println("Cassandra did not exit in", c.cfg.StopTimeout, "sending SIGKILL to", cmd.Process.Pid)
+		if err := cmd.Process.Kill(); err != nil {
+			return err
+		}
+		killed = true
+		<-exited
+	}
+	// the file is opened again when the process is started
+	c.output.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !killed && !c.lastExit.Clean() {
+		return fmt.Errorf("Cassandra %s", c.lastExit.Reason)
+	}
 	return nil
 }
 
 func (c *cassandraProcess) Running() bool {
-	process, err := os.FindProcess(c.cmd.Process.Pid)
-	if err != nil {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.running()
+}
+
+func (c *cassandraProcess) running() bool {
+	if c.cmd == nil {
 		return false
 	}
-	err = process.Signal(syscall.Signal(0))
-	if err != nil {
+	select {
+	case <-c.exited:
 		return false
+	default:
+		return true
+	}
+}
+
+func (c *cassandraProcess) State() *ProcessState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state := &ProcessState{
+		Running:  c.running(),
+		Restarts: c.restarts,
+		LastExit: c.lastExit,
+	}
+	state.GaveUp = c.cmd != nil && !state.Running && !c.stopping && c.failures >= c.cfg.MaxRestarts
+	if state.Running {
+		state.Pid = c.cmd.Process.Pid
+		state.StartedAt = c.startedAt
+		state.Uptime = time.Since(c.startedAt).Truncate(time.Second).String()
 	}
-	return true
+	return state
+}
+
+func (c *cassandraProcess) SetReplaceAddress(addr string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cfg.setReplaceAddress(addr)
 }
 
-func (c *cassandraProcess) buildEnv() []string {
-	e := os.Environ()
-	cfg := c.cfg
-	e = append(e, cfg.Env()...)
-	return e
+func (c *cassandraProcess) Output() *Output {
+	return c.output
 }
@@ -0,0 +1,83 @@
+package cassandra
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClearLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buddy-clear")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	exe, cleanup := testExecutable(t, "exec sleep 60")
+	defer cleanup()
+
+	cfg := DefaultConfig()
+	cfg.Executable = exe
+	cfg.LogFile = ""
+	cfg.CommitPath = filepath.Join(dir, "commitlog")
+	cfg.CachePath = filepath.Join(dir, "saved_caches")
+	cfg.HintsPath = filepath.Join(dir, "hints")
+	files := []string{
+		filepath.Join(cfg.CommitPath, "CommitLog-6-1460628403086.log"),
+		filepath.Join(cfg.CachePath, "KeyCache-e.db"),
+	}
+	for _, f := range files {
+		os.MkdirAll(filepath.Dir(f), os.ModePerm)
+		if err := ioutil.WriteFile(f, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := New(cfg)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ClearLogs(); err == nil {
+		t.Fatal("commit logs cleared while cassandra is running")
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	// a missing hints directory is not an error
+	if err := p.ClearLogs(); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Fatalf("%s was not removed", f)
+		}
+	}
+	if _, err := os.Stat(cfg.CommitPath); err != nil {
+		t.Fatal("commit log directory was removed")
+	}
+}
+
+func TestHasState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buddy-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := DefaultConfig()
+	cfg.DataPaths = []string{filepath.Join(dir, "data")}
+	cfg.CommitPath = filepath.Join(dir, "commitlog")
+	cfg.CachePath = filepath.Join(dir, "saved_caches")
+
+	// missing and empty directories are no state
+	table := filepath.Join(dir, "data", "ks", "t-5a1c395e")
+	os.MkdirAll(table, os.ModePerm)
+	if has, err := HasState(cfg); err != nil || has {
+		t.Fatalf("empty directories have state: %v %v", has, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(table, "mc-1-big-Data.db"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if has, err := HasState(cfg); err != nil || !has {
+		t.Fatalf("sstable is not state: %v %v", has, err)
+	}
+}
@@ -0,0 +1,112 @@
+package cassandra
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// ProcessState describes the cassandra process.
+type ProcessState struct {
+	Pid       int       `json:"pid,omitempty"`
+	Running   bool      `json:"running"`
+	StartedAt time.Time `json:"started_at,omitempty"`
+	Uptime    string    `json:"uptime,omitempty"`
+	Restarts  int       `json:"restarts"`
+	LastExit  *Exit     `json:"last_exit,omitempty"`
+	// GaveUp is set once the process failed MaxRestarts times in a row and is
+	// not restarted again until Start.
+	GaveUp bool `json:"gave_up,omitempty"`
+}
+
+// Exit records how the cassandra process exited.
+type Exit struct {
+	Time   time.Time `json:"time"`
+	Code   int       `json:"code"`
+	Signal string    `json:"signal,omitempty"`
+	Reason string    `json:"reason"`
+	// Stopped is set when buddy stopped the process.
+	Stopped bool `json:"stopped"`
+}
+
+// Clean reports whether the process exited normally or on SIGTERM.
+func (e *Exit) Clean() bool {
+	// 128 + SIGTERM = 143
+	return e.Code == 0 || e.Code == 143 || e.Signal == syscall.SIGTERM.String()
+}
+
+func newExit(err error, stopped bool) *Exit {
+	exit := &Exit{Time: time.Now(), Stopped: stopped}
+	if execErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := execErr.Sys().(syscall.WaitStatus); ok {
+			exit.Code = status.ExitStatus()
+			if status.Signaled() {
+				exit.Signal = status.Signal().String()
+			}
+		}
+	} else if err != nil {
+		exit.Code = -1
+		exit.Reason = err.Error()
+		return exit
+	}
+	switch {
+	case exit.Signal != "":
+		exit.Reason = "killed by " + exit.Signal
+	default:
+		exit.Reason = fmt.Sprintf("exited with status %d", exit.Code)
+	}
+	return exit
+}
+
+// supervise waits for cmd to exit and restarts cassandra unless it was stopped.
+func (c *cassandraProcess) supervise(cmd *exec.Cmd, exited chan struct{}, run int) {
+	err := cmd.Wait()
+
+	c.mu.Lock()
+	c.lastExit = newExit(err, c.stopping)
+	close(exited)
+	log.Println("Cassandra", c.lastExit.Reason)
+	if c.stopping || c.runs != run {
+		c.mu.Unlock()
+		return
+	}
+	// a node that stayed up longer than the longest backoff starts over
+	if c.lastExit.Time.Sub(c.startedAt) > c.cfg.MaxRestartBackoff {
+		c.failures = 0
+	}
+	c.mu.Unlock()
+	c.restart(run)
+}
+
+// restart starts cassandra again after a backoff that doubles with each
+// consecutive failure, until cfg.MaxRestarts is reached.
+func (c *cassandraProcess) restart(run int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		if c.failures >= c.cfg.MaxRestarts {
+			log.Println("Cassandra failed", c.failures, "times in a row, not restarting")
+			return
+		}
+		backoff := c.cfg.RestartBackoff << uint(c.failures)
+		if backoff > c.cfg.MaxRestartBackoff {
+			backoff = c.cfg.MaxRestartBackoff
+		}
+		c.failures++
+		log.Println("Restarting cassandra in", backoff)
+		c.mu.Unlock()
+		time.Sleep(backoff)
+		c.mu.Lock()
+		if c.stopping || c.runs != run {
+			return
+		}
+		c.restarts++
+		err := c.start()
+		if err == nil {
+			return
+		}
+		log.Println("Failed to restart cassandra", err)
+	}
+}
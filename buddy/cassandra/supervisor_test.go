@@ -0,0 +1,103 @@
+package cassandra
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testExecutable(t *testing.T, script string) (string, func()) {
+	dir, err := ioutil.TempDir("", "buddy-supervisor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(dir, "cassandra")
+	if err := ioutil.WriteFile(exe, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return exe, func() { os.RemoveAll(dir) }
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSupervisorRestarts(t *testing.T) {
+	exe, cleanup := testExecutable(t, "exit 3")
+	defer cleanup()
+	cfg := DefaultConfig()
+	cfg.Executable = exe
+	cfg.LogFile = ""
+	cfg.MaxRestarts = 2
+	cfg.RestartBackoff = 10 * time.Millisecond
+	p := New(cfg)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		s := p.State()
+		return s.Restarts == 2 && !s.Running
+	})
+	time.Sleep(50 * time.Millisecond)
+	s := p.State()
+	if s.Restarts != 2 || !s.GaveUp {
+		t.Fatalf("expected restarts to stop at 2, got %+v", s)
+	}
+	if s.LastExit == nil || s.LastExit.Code != 3 || s.LastExit.Stopped {
+		t.Fatalf("unexpected last exit %+v", s.LastExit)
+	}
+}
+
+func TestSupervisorStop(t *testing.T) {
+	exe, cleanup := testExecutable(t, "exec sleep 60")
+	defer cleanup()
+	cfg := DefaultConfig()
+	cfg.Executable = exe
+	cfg.LogFile = ""
+	cfg.RestartBackoff = 10 * time.Millisecond
+	p := New(cfg)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if s := p.State(); !s.Running || s.Pid == 0 {
+		t.Fatalf("expected a running process, got %+v", s)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	s := p.State()
+	if s.Running || s.Restarts != 0 || !s.LastExit.Stopped || s.GaveUp {
+		t.Fatalf("stopped process was restarted: %+v", s)
+	}
+}
+
+func TestSupervisorKill(t *testing.T) {
+	exe, cleanup := testExecutable(t, "trap '' TERM; exec sleep 60")
+	defer cleanup()
+	cfg := DefaultConfig()
+	cfg.Executable = exe
+	cfg.LogFile = ""
+	cfg.StopTimeout = 100 * time.Millisecond
+	p := New(cfg)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// give sh time to install the trap before signalling
+	time.Sleep(100 * time.Millisecond)
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	s := p.State()
+	if s.Running || s.LastExit.Signal != "killed" {
+		t.Fatalf("expected cassandra to be killed, got %+v", s.LastExit)
+	}
+}
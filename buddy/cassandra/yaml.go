@@ -1 +1,248 @@
-package cassaandra
+package cassandra
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const simpleSeedProvider = "org.apache.cassandra.locator.SimpleSeedProvider"
+
+// Yaml is a cassandra.yaml document. Settings are kept in file order and the
+// ones buddy does not manage are written back untouched.
+type Yaml struct {
+	settings yaml.MapSlice
+}
+
+// LoadYaml reads a cassandra.yaml file.
+func LoadYaml(path string) (*Yaml, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseYaml(d)
+}
+
+// ParseYaml parses cassandra.yaml contents.
+func ParseYaml(d []byte) (*Yaml, error) {
+	y := &Yaml{}
+	if err := yaml.Unmarshal(d, &y.settings); err != nil {
+		return nil, err
+	}
+	return y, nil
+}
+
+// Get returns the value of a top level setting.
+func (y *Yaml) Get(key string) (interface{}, bool) {
+	for _, item := range y.settings {
+		if item.Key == key {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set replaces a top level setting or appends it when missing.
+func (y *Yaml) Set(key string, value interface{}) {
+	for i, item := range y.settings {
+		if item.Key == key {
+			y.settings[i].Value = value
+			return
+		}
+	}
+	y.settings = append(y.settings, yaml.MapItem{Key: key, Value: value})
+}
+
+// Apply overrides the settings buddy manages with the values from cfg, empty
+// values leave the template setting alone.
+func (y *Yaml) Apply(cfg *Config) {
+	if cfg.ClusterName != "" {
+		y.Set("cluster_name", cfg.ClusterName)
+	}
+	if len(cfg.Seeds) > 0 {
+		y.Set("seed_provider", []yaml.MapSlice{{
+			{Key: "class_name", Value: simpleSeedProvider},
+			{Key: "parameters", Value: []yaml.MapSlice{{
+				{Key: "seeds", Value: strings.Join(cfg.Seeds, ",")},
+			}}},
+		}})
+	}
+	if cfg.ListenAddress != "" {
+		y.Set("listen_address", cfg.ListenAddress)
+	}
+	if cfg.RPCAddress != "" {
+		y.Set("rpc_address", cfg.RPCAddress)
+	}
+	if cfg.Snitch != "" {
+		y.Set("endpoint_snitch", cfg.Snitch)
+	}
+	if len(cfg.DataPaths) > 0 {
+		y.Set("data_file_directories", cfg.DataPaths)
+	}
+	if cfg.CommitPath != "" {
+		y.Set("commitlog_directory", cfg.CommitPath)
+	}
+	if cfg.CachePath != "" {
+		y.Set("saved_caches_directory", cfg.CachePath)
+	}
+	if cfg.HintsPath != "" {
+		y.Set("hints_directory", cfg.HintsPath)
+	}
+	if cfg.IncrementalBackups {
+		y.Set("incremental_backups", true)
+	}
+}
+
+func (y *Yaml) Bytes() ([]byte, error) {
+	return yaml.Marshal(y.settings)
+}
+
+// Write writes the document to path, the file is replaced atomically so a
+// starting node never reads a partial config.
+func (y *Yaml) Write(path string) error {
+	d, err := y.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, d, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// RenderYaml loads the template of cfg, applies cfg and writes the result to cfg.YamlPath.
+func RenderYaml(cfg *Config) error {
+	y, err := LoadYaml(cfg.YamlTemplate)
+	if err != nil {
+		return err
+	}
+	y.Apply(cfg)
+	return y.Write(cfg.YamlPath)
+}
+
+// Config populates cfg with the paths, ports and cluster settings of the document.
+func (y *Yaml) Config(cfg *Config) {
+	if dirs := y.strings("data_file_directories"); len(dirs) > 0 {
+		cfg.DataPaths = dirs
+	}
+	if v := y.string("commitlog_directory"); v != "" {
+		cfg.CommitPath = v
+	}
+	if v := y.string("saved_caches_directory"); v != "" {
+		cfg.CachePath = v
+	}
+	if v := y.string("hints_directory"); v != "" {
+		cfg.HintsPath = v
+	}
+	if v := y.string("cluster_name"); v != "" {
+		cfg.ClusterName = v
+	}
+	if v := y.string("listen_address"); v != "" {
+		cfg.ListenAddress = v
+	}
+	if v := y.string("rpc_address"); v != "" {
+		cfg.RPCAddress = v
+	}
+	if v := y.string("endpoint_snitch"); v != "" {
+		cfg.Snitch = v
+	}
+	if v, ok := y.Get("native_transport_port"); ok {
+		if port, ok := v.(int); ok {
+			cfg.NativePort = port
+		}
+	}
+	if v, ok := y.Get("incremental_backups"); ok {
+		cfg.IncrementalBackups, _ = v.(bool)
+	}
+	if seeds := y.seeds(); len(seeds) > 0 {
+		cfg.Seeds = seeds
+	}
+}
+
+// Validate reports settings that keep buddy from taking complete backups or
+// restoring them.
+func (y *Yaml) Validate() []string {
+	problems := make([]string, 0)
+	if len(y.strings("data_file_directories")) == 0 {
+		problems = append(problems, "data_file_directories is not set, cassandra falls back to a directory under its install")
+	}
+	if v, ok := y.Get("auto_snapshot"); ok && v == false {
+		problems = append(problems, "auto_snapshot is off, data removed by TRUNCATE or DROP can not be recovered from between backups")
+	}
+	if v, ok := y.Get("start_native_transport"); ok && v == false {
+		problems = append(problems, "start_native_transport is off, buddy reads the schema over the native transport")
+	}
+	if y.string("commitlog_directory") == "" {
+		problems = append(problems, "commitlog_directory is not set, restore can not clear the commit log")
+	}
+	return problems
+}
+
+// ReadYaml populates cfg from the cassandra.yaml at path and returns the
+// problems Validate found in it.
+func ReadYaml(path string, cfg *Config) ([]string, error) {
+	y, err := LoadYaml(path)
+	if err != nil {
+		return nil, err
+	}
+	y.Config(cfg)
+	return y.Validate(), nil
+}
+
+func (y *Yaml) string(key string) string {
+	v, _ := y.Get(key)
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func (y *Yaml) strings(key string) []string {
+	v, _ := y.Get(key)
+	list, _ := v.([]interface{})
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		strs = append(strs, fmt.Sprint(item))
+	}
+	return strs
+}
+
+// seeds returns the seeds of a SimpleSeedProvider.
+func (y *Yaml) seeds() []string {
+	v, _ := y.Get("seed_provider")
+	providers, _ := v.([]interface{})
+	for _, provider := range providers {
+		p, _ := provider.(yaml.MapSlice)
+		params, _ := mapSliceValue(p, "parameters").([]interface{})
+		for _, param := range params {
+			ps, _ := param.(yaml.MapSlice)
+			if seeds, ok := mapSliceValue(ps, "seeds").(string); ok {
+				list := make([]string, 0)
+				for _, seed := range strings.Split(seeds, ",") {
+					if seed = strings.TrimSpace(seed); seed != "" {
+						list = append(list, seed)
+					}
+				}
+				return list
+			}
+		}
+	}
+	return nil
+}
+
+func mapSliceValue(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
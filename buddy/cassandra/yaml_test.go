@@ -0,0 +1,102 @@
+package cassandra
+
+import (
+	"strings"
+	"testing"
+)
+
+var testYaml = []byte(`cluster_name: 'Test Cluster'
+num_tokens: 256
+hinted_handoff_enabled: true
+data_file_directories:
+    - /var/lib/cassandra/data
+commitlog_directory: /var/lib/cassandra/commitlog
+seed_provider:
+    - class_name: org.apache.cassandra.locator.SimpleSeedProvider
+      parameters:
+          - seeds: "127.0.0.1"
+listen_address: localhost
+incremental_backups: false
+endpoint_snitch: SimpleSnitch
+`)
+
+func TestYamlApply(t *testing.T) {
+	y, err := ParseYaml(testYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.ClusterName = "challenge-cassandra"
+	cfg.Seeds = []string{"10.0.0.1", "10.0.0.2"}
+	cfg.ListenAddress = "10.0.0.3"
+	cfg.IncrementalBackups = true
+	y.Apply(cfg)
+
+	d, err := y.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(d)
+	for _, want := range []string{
+		"cluster_name: challenge-cassandra\n",
+		"num_tokens: 256\n",
+		"- " + cfg.DataPaths[0] + "\n",
+		"commitlog_directory: " + cfg.CommitPath + "\n",
+		"saved_caches_directory: " + cfg.CachePath + "\n",
+		"seeds: 10.0.0.1,10.0.0.2\n",
+		"listen_address: 10.0.0.3\n",
+		"incremental_backups: true\n",
+		"endpoint_snitch: SimpleSnitch\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("rendered yaml is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "cluster_name") > strings.Index(out, "num_tokens") {
+		t.Fatal("settings were reordered")
+	}
+
+	// incremental backups enabled in the template stay enabled
+	cfg.IncrementalBackups = false
+	y.Apply(cfg)
+	if v, _ := y.Get("incremental_backups"); v != true {
+		t.Fatalf("incremental_backups was overwritten with %v", v)
+	}
+}
+
+var testExistingYaml = []byte(`cluster_name: 'Prod Cluster'
+native_transport_port: 9142
+auto_snapshot: false
+data_file_directories:
+    - /data1/cassandra
+    - /data2/cassandra
+commitlog_directory: /commitlog
+saved_caches_directory: /caches
+seed_provider:
+    - class_name: org.apache.cassandra.locator.SimpleSeedProvider
+      parameters:
+          - seeds: "10.0.0.1, 10.0.0.2"
+`)
+
+func TestYamlConfig(t *testing.T) {
+	y, err := ParseYaml(testExistingYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	y.Config(cfg)
+	if len(cfg.DataPaths) != 2 || cfg.DataPaths[1] != "/data2/cassandra" || cfg.CommitPath != "/commitlog" || cfg.CachePath != "/caches" {
+		t.Fatalf("unexpected paths %+v", cfg)
+	}
+	if cfg.NativePort != 9142 || cfg.ClusterName != "Prod Cluster" {
+		t.Fatalf("unexpected port or cluster name %+v", cfg)
+	}
+	if len(cfg.Seeds) != 2 || cfg.Seeds[1] != "10.0.0.2" {
+		t.Fatalf("unexpected seeds %v", cfg.Seeds)
+	}
+
+	problems := y.Validate()
+	if len(problems) != 1 || !strings.Contains(problems[0], "auto_snapshot") {
+		t.Fatalf("unexpected problems %v", problems)
+	}
+}
@@ -1,15 +1,121 @@
 package buddy
 
-import "github.com/Nomon/cassandra-buddy/buddy/structs"
+import (
+	"errors"
+
+	"github.com/Nomon/cassandra-buddy/buddy/structs"
+)
 
 type Cassandra struct {
 	srv *Server
 }
 
+// Start starts cassandra if it is not running, it refuses while restores run
+// unless forced.
 func (c *Cassandra) Start(args *structs.CassandraStartRequest, reply *structs.CassandraStartReply) error {
+	logger := c.srv.logger(args)
+	if err := c.srv.operations.start(args.Force); err != nil {
+		logger.Error("Failed to start cassandra", "error", err)
+		return err
+	}
+	if err := c.srv.startCassandra(args.Wait); err != nil {
+		logger.Error("Failed to start cassandra", "error", err)
+		return err
+	}
+	reply.Process = c.srv.process().State()
+	return nil
+}
+
+// Stop drains and stops cassandra, it refuses while backups or restores run
+// unless forced.
+func (c *Cassandra) Stop(args *structs.CassandraStopRequest, reply *structs.CassandraStopReply) error {
+	logger := c.srv.logger(args)
+	if err := c.srv.operations.stop(args.Force, nil, c.srv.stopCassandra); err != nil {
+		logger.Error("Failed to stop cassandra", "error", err)
+		return err
+	}
+	reply.Process = c.srv.process().State()
+	return nil
+}
+
+// Restart stops and starts cassandra.
+func (c *Cassandra) Restart(args *structs.CassandraRestartRequest, reply *structs.CassandraRestartReply) error {
+	logger := c.srv.logger(args)
+	if err := c.srv.operations.stop(args.Force, nil, c.srv.stopCassandra); err != nil {
+		logger.Error("Failed to stop cassandra", "error", err)
+		return err
+	}
+	if err := c.srv.startCassandra(args.Wait); err != nil {
+		logger.Error("Failed to start cassandra", "error", err)
+		return err
+	}
+	reply.Process = c.srv.process().State()
+	return nil
+}
+
+// Status returns the process state, readiness and the operations in progress.
+func (c *Cassandra) Status(args *structs.CassandraStatusRequest, reply *structs.CassandraStatusReply) error {
+	reply.Process = c.srv.process().State()
+	readiness, info, cluster := c.srv.node()
+	if readiness != nil {
+		reply.Readiness = readiness.Phase()
+	}
+	if info != nil {
+		reply.HostID = info.ID
+	}
+	if cluster != nil {
+		reply.Cluster = cluster.Name
+	}
+	reply.Operations = c.srv.operations.list()
+	return nil
+}
+
+// Process returns the state of the cassandra process.
+func (c *Cassandra) Process(args *structs.CassandraProcessRequest, reply *structs.CassandraProcessReply) error {
+	reply.Process = c.srv.process().State()
+	if readiness, _, _ := c.srv.node(); readiness != nil {
+		reply.Readiness = readiness.Phase()
+	}
+	return nil
+}
+
+// Health returns the node statistics and the problems found in them.
+func (c *Cassandra) Health(args *structs.CassandraHealthRequest, reply *structs.CassandraHealthReply) error {
+	c.srv.health(reply)
+	return nil
+}
+
+// Logs returns the last lines cassandra wrote to stdout and stderr.
+func (c *Cassandra) Logs(args *structs.CassandraLogsRequest, reply *structs.CassandraLogsReply) error {
+	output := c.srv.process().Output()
+	if output == nil {
+		return errors.New("Cassandra output is not captured for an attached cassandra")
+	}
+	reply.Lines = output.Tail(args.Tail)
+	return nil
+}
+
+// Replace restarts cassandra as the replacement of a dead node.
+func (c *Cassandra) Replace(args *structs.CassandraReplaceRequest, reply *structs.CassandraReplaceReply) error {
+	logger := c.srv.logger(args)
+	if err := args.Validate(); err != nil {
+		logger.Error("Cassandra.Replace Validation failed", "error", err)
+		return err
+	}
+	replacement, err := c.srv.replace(args)
+	if err != nil {
+		logger.Error("Failed to start node replacement", "error", err)
+		return err
+	}
+	reply.Replacement = replacement
 	return nil
 }
 
-func (c *Cassandra) Stop(args *structs.CassandraStartRequest, reply *structs.CassandraStartReply) error {
+// Replacement returns the progress of the node replacement.
+func (c *Cassandra) Replacement(args *structs.CassandraReplacementRequest, reply *structs.CassandraReplaceReply) error {
+	reply.Replacement = c.srv.replacement()
+	if reply.Replacement == nil {
+		return errors.New("No node replacement was started")
+	}
 	return nil
 }
@@ -1,11 +1,99 @@
 package buddy
 
+import (
+	"time"
+
+	"github.com/Nomon/cassandra-buddy/buddy/cassandra"
+	"github.com/Nomon/cassandra-buddy/buddy/cqlsh"
+	"github.com/Nomon/cassandra-buddy/buddy/nodetool"
+)
+
 type Config struct {
 	LogLevel     string
 	CqlshAddr    string
 	NodetoolAddr string
 	Hostname     string
 
+	// nodetool settings, the JMX port is the one cassandra is configured with
+	NodetoolBinary       string
+	NodetoolUsername     string
+	NodetoolPasswordFile string
+	NodetoolSSL          bool
+	// JolokiaURL replaces nodetool with the jolokia agent when set
+	JolokiaURL      string
+	JolokiaUsername string
+	JolokiaPassword string
+
+	// CQL native transport settings, the native_transport_port of CassandraYaml
+	// overrides CqlPort
+	CqlPort        int
+	CqlUsername    string
+	CqlPassword    string
+	CqlTLS         bool
+	CqlCAFile      string
+	CqlCertFile    string
+	CqlKeyFile     string
+	CqlConsistency string
+
+	// StartTimeout is how long cassandra has to become ready after it is
+	// started.
+	StartTimeout time.Duration
+
+	// ReplaceTimeout is how long replacing a dead node may take, bootstrap
+	// streams all of the node's data.
+	ReplaceTimeout time.Duration
+
+	// DrainTimeout is how long to wait for nodetool drain before cassandra is
+	// stopped anyway.
+	DrainTimeout time.Duration
+
+	// SchemaAgreementTimeout is how long to wait for the cluster to agree on the
+	// schema after buddy changes it.
+	SchemaAgreementTimeout time.Duration
+
+	// SnapshotsKeep is how many uploaded snapshots are kept on disk for fast
+	// restores, older ones are cleared after each upload.
+	SnapshotsKeep int
+
+	// CompactionPendingLimit is how many compactions may be pending before
+	// backups are postponed and the node is reported unhealthy.
+	CompactionPendingLimit int
+	// CompactionPostponeTimeout is how long a backup waits for compactions.
+	CompactionPostponeTimeout time.Duration
+	// LargePartitionBytes reports tables with larger partitions in health.
+	LargePartitionBytes int64
+
+	// DiskSpaceMargin is the fraction of extra free space snapshots and restores
+	// require on top of their estimated size.
+	DiskSpaceMargin float64
+
+	// CassandraYaml is the cassandra.yaml of an externally configured node, its
+	// paths and ports are used instead of the defaults.
+	CassandraYaml string
+	// cassandra.yaml settings, the yaml is only rendered when a template is set
+	CassandraYamlTemplate string
+	ClusterName           string
+	Seeds                 []string
+	ListenAddress         string
+	RPCAddress            string
+	Snitch                string
+	IncrementalBackups    bool
+
+	// JVM settings, see cassandra.JVMOptions
+	CassandraJoinRing       bool
+	CassandraJVMProperties  map[string]string
+	CassandraGC             string
+	CassandraGCLogFile      string
+	CassandraJVMFlags       []string
+	CassandraJVMOptionsFile string
+
+	// CassandraAttach attaches to a cassandra started by systemd or kubernetes
+	// instead of running it, the commands start and stop it during restores.
+	CassandraAttach       bool
+	CassandraPidFile      string
+	CassandraStartCommand string
+	CassandraStopCommand  string
+
 	// S3 settings
 	// bucket to place backups in
 	// region where the bucket lives
@@ -21,7 +109,110 @@ func NewConfig() *Config {
 		S3Bucket:     "us-west-staging-media",
 		S3Region:     "us-west-1",
 		S3Path:       "/cassandra-backups",
-		CqlshAddr:    "192.168.33.100",
+		CqlshAddr:    "",
 		NodetoolAddr: "",
+
+		NodetoolBinary: "/usr/local/bin/nodetool",
+
+		CqlPort:        9042,
+		CqlConsistency: "LOCAL_QUORUM",
+
+		StartTimeout:           10 * time.Minute,
+		ReplaceTimeout:         24 * time.Hour,
+		DrainTimeout:           5 * time.Minute,
+		CassandraJoinRing:      true,
+		SchemaAgreementTimeout: 2 * time.Minute,
+		DiskSpaceMargin:        0.1,
+
+		CompactionPendingLimit:    100,
+		CompactionPostponeTimeout: time.Hour,
+		LargePartitionBytes:       100 * 1024 * 1024,
+	}
+}
+
+// CqlConfig returns the native transport client configuration for a node
+// listening on nativePort.
+func (cfg *Config) CqlConfig(nativePort int) (*cqlsh.Config, error) {
+	consistency, err := cqlsh.ParseConsistency(cfg.CqlConsistency)
+	if err != nil {
+		return nil, err
+	}
+	cqlcfg := cqlsh.DefaultConfig()
+	if cfg.CqlshAddr != "" {
+		cqlcfg.Hosts = []string{cfg.CqlshAddr}
+	}
+	cqlcfg.Port = nativePort
+	cqlcfg.Username = cfg.CqlUsername
+	cqlcfg.Password = cfg.CqlPassword
+	cqlcfg.TLS = cfg.CqlTLS
+	cqlcfg.CAFile = cfg.CqlCAFile
+	cqlcfg.CertFile = cfg.CqlCertFile
+	cqlcfg.KeyFile = cfg.CqlKeyFile
+	cqlcfg.Consistency = consistency
+	return cqlcfg, nil
+}
+
+// CassandraConfig returns the cassandra process configuration and the problems
+// found in CassandraYaml.
+func (cfg *Config) CassandraConfig() (*cassandra.Config, []string, error) {
+	cascfg := cassandra.DefaultConfig()
+	cascfg.NativePort = cfg.CqlPort
+	problems := make([]string, 0)
+	if cfg.CassandraYaml != "" {
+		var err error
+		if problems, err = cassandra.ReadYaml(cfg.CassandraYaml, cascfg); err != nil {
+			return nil, nil, err
+		}
+	}
+	cascfg.YamlTemplate = cfg.CassandraYamlTemplate
+	if cfg.ClusterName != "" {
+		cascfg.ClusterName = cfg.ClusterName
+	}
+	if len(cfg.Seeds) > 0 {
+		cascfg.Seeds = cfg.Seeds
+	}
+	if cfg.ListenAddress != "" {
+		cascfg.ListenAddress = cfg.ListenAddress
+	}
+	if cfg.RPCAddress != "" {
+		cascfg.RPCAddress = cfg.RPCAddress
+	}
+	if cfg.Snitch != "" {
+		cascfg.Snitch = cfg.Snitch
+	}
+	if cfg.IncrementalBackups {
+		cascfg.IncrementalBackups = true
+	}
+	cascfg.JoinRing = cfg.CassandraJoinRing
+	cascfg.JVM = cassandra.JVMOptions{
+		Properties: cfg.CassandraJVMProperties,
+		GC:         cfg.CassandraGC,
+		GCLogFile:  cfg.CassandraGCLogFile,
+		Flags:      cfg.CassandraJVMFlags,
+	}
+	cascfg.JVMOptionsFile = cfg.CassandraJVMOptionsFile
+	cascfg.PidFile = cfg.CassandraPidFile
+	cascfg.StartCommand = cfg.CassandraStartCommand
+	cascfg.StopCommand = cfg.CassandraStopCommand
+	return cascfg, problems, nil
+}
+
+// NodetoolConfig returns the nodetool configuration for a node listening for
+// JMX on jmxPort.
+func (cfg *Config) NodetoolConfig(jmxPort int) *nodetool.Config {
+	ntcfg := nodetool.DefaultConfig()
+	if cfg.NodetoolBinary != "" {
+		ntcfg.Binary = cfg.NodetoolBinary
+	}
+	if cfg.NodetoolAddr != "" {
+		ntcfg.Host = cfg.NodetoolAddr
 	}
+	ntcfg.Port = jmxPort
+	ntcfg.Username = cfg.NodetoolUsername
+	ntcfg.PasswordFile = cfg.NodetoolPasswordFile
+	ntcfg.SSL = cfg.NodetoolSSL
+	ntcfg.JolokiaURL = cfg.JolokiaURL
+	ntcfg.JolokiaUsername = cfg.JolokiaUsername
+	ntcfg.JolokiaPassword = cfg.JolokiaPassword
+	return ntcfg
 }
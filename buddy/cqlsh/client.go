@@ -0,0 +1,407 @@
+package cqlsh
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Consistency is a CQL consistency level.
+type Consistency uint16
+
+const (
+	Any         Consistency = 0x00
+	One         Consistency = 0x01
+	Two         Consistency = 0x02
+	Three       Consistency = 0x03
+	Quorum      Consistency = 0x04
+	All         Consistency = 0x05
+	LocalQuorum Consistency = 0x06
+	EachQuorum  Consistency = 0x07
+	Serial      Consistency = 0x08
+	LocalSerial Consistency = 0x09
+	LocalOne    Consistency = 0x0A
+)
+
+var consistencyNames = map[string]Consistency{
+	"ANY":          Any,
+	"ONE":          One,
+	"TWO":          Two,
+	"THREE":        Three,
+	"QUORUM":       Quorum,
+	"ALL":          All,
+	"LOCAL_QUORUM": LocalQuorum,
+	"EACH_QUORUM":  EachQuorum,
+	"SERIAL":       Serial,
+	"LOCAL_SERIAL": LocalSerial,
+	"LOCAL_ONE":    LocalOne,
+}
+
+// ParseConsistency parses a consistency level name like LOCAL_QUORUM.
+func ParseConsistency(name string) (Consistency, error) {
+	if c, ok := consistencyNames[strings.ToUpper(name)]; ok {
+		return c, nil
+	}
+	return 0, fmt.Errorf("Unknown consistency level %q", name)
+}
+
+func (c Consistency) String() string {
+	for name, v := range consistencyNames {
+		if v == c {
+			return name
+		}
+	}
+	return fmt.Sprintf("0x%02x", uint16(c))
+}
+
+// Config configures connections to cassandra's native transport.
+type Config struct {
+	// Hosts are tried in order until one accepts the connection.
+	Hosts    []string
+	Port     int
+	Username string
+	Password string
+	// TLS enables client encryption, CAFile verifies the server and
+	// CertFile/KeyFile are sent when the server requires client certificates.
+	TLS                bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+	Consistency        Consistency
+	Timeout            time.Duration
+}
+
+// DefaultConfig returns a config for an unauthenticated local node.
+func DefaultConfig() *Config {
+	return &Config{
+		Hosts:       []string{"127.0.0.1"},
+		Port:        9042,
+		Consistency: LocalQuorum,
+		Timeout:     10 * time.Second,
+	}
+}
+
+func (cfg *Config) tlsConfig(host string) (*tls.Config, error) {
+	tc := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tc.RootCAs = x509.NewCertPool()
+		if !tc.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in %s", cfg.CAFile)
+		}
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+// Error is an error response from cassandra.
+type Error struct {
+	Code    int32
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("cql error 0x%04x: %s", e.Code, e.Message)
+}
+
+const errProtocol = 0x000A
+
+// Column describes a result column.
+type Column struct {
+	Keyspace string
+	Table    string
+	Name     string
+	Type     TypeInfo
+}
+
+// Row maps column names to decoded values.
+type Row map[string]interface{}
+
+// String returns a text column, empty when null.
+func (r Row) String(name string) string {
+	s, _ := r[name].(string)
+	return s
+}
+
+// Bool returns a boolean column, false when null.
+func (r Row) Bool(name string) bool {
+	b, _ := r[name].(bool)
+	return b
+}
+
+// Int returns an integer column of any size, 0 when null.
+func (r Row) Int(name string) int64 {
+	switch v := r[name].(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	}
+	return 0
+}
+
+// StringMap returns a map<text, text> column.
+func (r Row) StringMap(name string) map[string]string {
+	m, _ := r[name].(map[string]interface{})
+	sm := make(map[string]string, len(m))
+	for k, v := range m {
+		sm[k] = fmt.Sprint(v)
+	}
+	return sm
+}
+
+// Strings returns a list<text> or set<text> column.
+func (r Row) Strings(name string) []string {
+	list, _ := r[name].([]interface{})
+	sl := make([]string, 0, len(list))
+	for _, v := range list {
+		sl = append(sl, fmt.Sprint(v))
+	}
+	return sl
+}
+
+// Result is the outcome of a query, Rows is empty for statements that do not
+// return rows.
+type Result struct {
+	Columns []Column
+	Rows    []Row
+}
+
+// Conn is a connection to a single node.
+type Conn struct {
+	conn    net.Conn
+	version byte
+	timeout time.Duration
+	mu      sync.Mutex
+}
+
+// Dial connects to the first reachable host of cfg and authenticates.
+func Dial(cfg *Config) (*Conn, error) {
+	var lastErr error
+	for _, host := range cfg.Hosts {
+		for _, version := range []byte{4, 3} {
+			c, err := dial(cfg, host, version)
+			if err == nil {
+				return c, nil
+			}
+			lastErr = err
+			// older nodes reject the newer protocol version, anything
+			// else will not get better with a downgrade.
+			if cqlErr, ok := err.(*Error); !ok || cqlErr.Code != errProtocol {
+				break
+			}
+		}
+	}
+	if lastErr == nil {
+		lastErr = errors.New("cql: no hosts configured")
+	}
+	return nil, lastErr
+}
+
+func dial(cfg *Config, host string, version byte) (*Conn, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(cfg.Port))
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	var conn net.Conn
+	var err error
+	if cfg.TLS {
+		tc, tlsErr := cfg.tlsConfig(host)
+		if tlsErr != nil {
+			return nil, tlsErr
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tc)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{conn: conn, version: version, timeout: cfg.Timeout}
+	if err := c.startup(cfg); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Conn) startup(cfg *Config) error {
+	e := &encoder{}
+	e.stringMap(map[string]string{"CQL_VERSION": "3.0.0"})
+	f, err := c.roundTrip(opStartup, e.buf)
+	if err != nil {
+		return err
+	}
+	switch f.opcode {
+	case opReady:
+		return nil
+	case opAuthenticate:
+		return c.authenticate(cfg)
+	}
+	return fmt.Errorf("cql: unexpected opcode 0x%02x during startup", f.opcode)
+}
+
+// authenticate answers the PasswordAuthenticator SASL PLAIN exchange.
+func (c *Conn) authenticate(cfg *Config) error {
+	if cfg.Username == "" {
+		return errors.New("cql: server requires authentication but no username is configured")
+	}
+	token := []byte("\x00" + cfg.Username + "\x00" + cfg.Password)
+	for {
+		e := &encoder{}
+		e.bytes(token)
+		f, err := c.roundTrip(opAuthResponse, e.buf)
+		if err != nil {
+			return err
+		}
+		switch f.opcode {
+		case opAuthSuccess:
+			return nil
+		case opAuthChallenge:
+			continue
+		}
+		return fmt.Errorf("cql: unexpected opcode 0x%02x during authentication", f.opcode)
+	}
+}
+
+// Query runs a statement and pages through all of its rows.
+func (c *Conn) Query(stmt string, consistency Consistency) (*Result, error) {
+	result := &Result{Rows: make([]Row, 0)}
+	var pagingState []byte
+	for {
+		e := &encoder{}
+		e.longString(stmt)
+		e.short(uint16(consistency))
+		flags := byte(0x04) // page size
+		if pagingState != nil {
+			flags |= 0x08
+		}
+		e.byte(flags)
+		e.int(5000)
+		if pagingState != nil {
+			e.bytes(pagingState)
+		}
+		f, err := c.roundTrip(opQuery, e.buf)
+		if err != nil {
+			return nil, err
+		}
+		if f.opcode != opResult {
+			return nil, fmt.Errorf("cql: unexpected opcode 0x%02x for query", f.opcode)
+		}
+		if pagingState, err = decodeResult(f.body, result); err != nil {
+			return nil, err
+		}
+		if pagingState == nil {
+			return result, nil
+		}
+	}
+}
+
+func (c *Conn) roundTrip(opcode byte, body []byte) (*frame, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	if err := writeFrame(c.conn, &frame{version: c.version, opcode: opcode, body: body}); err != nil {
+		return nil, err
+	}
+	f, err := readFrame(c.conn)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{buf: f.body}
+	if f.flags&flagTracing != 0 {
+		d.next(16)
+	}
+	if f.flags&flagWarning != 0 {
+		d.stringList()
+	}
+	if f.flags&flagCustomPayload != 0 {
+		d.bytesMap()
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	f.body = d.buf
+	if f.opcode == opError {
+		return nil, &Error{Code: d.int(), Message: d.string()}
+	}
+	return f, nil
+}
+
+const resultRows = 0x0002
+
+// decodeResult appends the rows of a RESULT body to result and returns the
+// paging state when more pages are available.
+func decodeResult(body []byte, result *Result) ([]byte, error) {
+	d := &decoder{buf: body}
+	kind := d.int()
+	if kind != resultRows {
+		return nil, d.err
+	}
+	flags := d.int()
+	count := int(d.int())
+	var pagingState []byte
+	if flags&0x0002 != 0 {
+		pagingState = d.bytes()
+	}
+	if flags&0x0004 == 0 {
+		result.Columns = make([]Column, 0, count)
+		var keyspace, table string
+		global := flags&0x0001 != 0
+		if global {
+			keyspace = d.string()
+			table = d.string()
+		}
+		for i := 0; i < count && d.err == nil; i++ {
+			col := Column{Keyspace: keyspace, Table: table}
+			if !global {
+				col.Keyspace = d.string()
+				col.Table = d.string()
+			}
+			col.Name = d.string()
+			col.Type = d.typeInfo()
+			result.Columns = append(result.Columns, col)
+		}
+	}
+	rows := int(d.int())
+	for i := 0; i < rows && d.err == nil; i++ {
+		row := make(Row, len(result.Columns))
+		for _, col := range result.Columns {
+			v, err := col.Type.Decode(d.bytes())
+			if err != nil {
+				return nil, err
+			}
+			row[col.Name] = v
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return pagingState, d.err
+}
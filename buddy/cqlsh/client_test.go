@@ -0,0 +1,200 @@
+package cqlsh
+
+import (
+	"net"
+	"strconv"
+	"testing"
+)
+
+// fakeNode answers a password authenticated startup and a single query with
+// one row of (text, map<text, text>, int).
+func fakeNode(t *testing.T, l net.Listener) {
+	conn, err := l.Accept()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+	reply := func(req *frame, opcode byte, body []byte) {
+		if err := writeFrame(conn, &frame{version: 0x80 | req.version, stream: req.stream, opcode: opcode, body: body}); err != nil {
+			t.Error(err)
+		}
+	}
+	for {
+		req, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+		switch req.opcode {
+		case opStartup:
+			e := &encoder{}
+			e.string("org.apache.cassandra.auth.PasswordAuthenticator")
+			reply(req, opAuthenticate, e.buf)
+		case opAuthResponse:
+			d := &decoder{buf: req.body}
+			if string(d.bytes()) != "\x00cassandra\x00secret" {
+				e := &encoder{}
+				e.int(0x0100)
+				e.string("Bad credentials")
+				reply(req, opError, e.buf)
+				continue
+			}
+			reply(req, opAuthSuccess, nil)
+		case opQuery:
+			d := &decoder{buf: req.body}
+			if d.longString() != "SELECT * FROM system_schema.keyspaces" || Consistency(d.short()) != One {
+				t.Error("unexpected query")
+			}
+			e := &encoder{}
+			e.int(resultRows)
+			e.int(0x0001) // global table spec
+			e.int(3)
+			e.string("system_schema")
+			e.string("keyspaces")
+			e.string("keyspace_name")
+			e.short(TypeVarchar)
+			e.string("replication")
+			e.short(TypeMap)
+			e.short(TypeVarchar)
+			e.short(TypeVarchar)
+			e.string("version")
+			e.short(TypeInt)
+			e.int(1)
+			e.bytes([]byte("app"))
+			m := &encoder{}
+			m.int(1)
+			m.bytes([]byte("class"))
+			m.bytes([]byte("SimpleStrategy"))
+			e.bytes(m.buf)
+			e.bytes([]byte{0, 0, 0, 3})
+			reply(req, opResult, e.buf)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go fakeNode(t, l)
+
+	cfg := DefaultConfig()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	cfg.Hosts = []string{host}
+	cfg.Port, _ = strconv.Atoi(port)
+	cfg.Username = "cassandra"
+	cfg.Password = "secret"
+	cfg.Consistency = One
+	cql := NeqCql(cfg)
+	defer cql.Close()
+
+	result, err := cql.Query("SELECT * FROM system_schema.keyspaces")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Columns) != 3 || result.Columns[1].Keyspace != "system_schema" {
+		t.Fatalf("unexpected columns %+v", result.Columns)
+	}
+	if len(result.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(result.Rows))
+	}
+	row := result.Rows[0]
+	if row.String("keyspace_name") != "app" {
+		t.Fatal("failed to decode text column")
+	}
+	if row.StringMap("replication")["class"] != "SimpleStrategy" {
+		t.Fatal("failed to decode map column")
+	}
+	if row.Int("version") != 3 {
+		t.Fatal("failed to decode int column")
+	}
+}
+
+// trackingListener hands out the accepted connections so tests can drop them.
+type trackingListener struct {
+	net.Listener
+	conns chan net.Conn
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.conns <- conn
+	}
+	return conn, err
+}
+
+func TestQueryAfterRestart(t *testing.T) {
+	base, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer base.Close()
+	l := &trackingListener{Listener: base, conns: make(chan net.Conn, 2)}
+	go fakeNode(t, l)
+
+	cfg := DefaultConfig()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	cfg.Hosts = []string{host}
+	cfg.Port, _ = strconv.Atoi(port)
+	cfg.Username = "cassandra"
+	cfg.Password = "secret"
+	cfg.Consistency = One
+	cql := NeqCql(cfg)
+	defer cql.Close()
+
+	if _, err := cql.Query("SELECT * FROM system_schema.keyspaces"); err != nil {
+		t.Fatal(err)
+	}
+	// the node restarts, the open connection is gone
+	(<-l.conns).Close()
+	go fakeNode(t, l)
+	result, err := cql.Query("SELECT * FROM system_schema.keyspaces")
+	if err != nil {
+		t.Fatalf("query was not retried on a new connection: %v", err)
+	}
+	if len(result.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(result.Rows))
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		typ  TypeInfo
+		data []byte
+		want string
+	}{
+		{TypeInfo{ID: TypeUUID}, []byte{0x55, 0x0e, 0x84, 0x00, 0xe2, 0x9b, 0x41, 0xd4, 0xa7, 0x16, 0x44, 0x66, 0x55, 0x44, 0x00, 0x00}, "550e8400-e29b-41d4-a716-446655440000"},
+		{TypeInfo{ID: TypeVarint}, []byte{0xff, 0x00}, "-256"},
+		{TypeInfo{ID: TypeDecimal}, []byte{0, 0, 0, 2, 0x04, 0xd2}, "12.34"},
+		{TypeInfo{ID: TypeDecimal}, []byte{0, 0, 0, 3, 0xfb}, "-0.005"},
+		{TypeInfo{ID: TypeBoolean}, []byte{1}, "true"},
+		{TypeInfo{ID: TypeInet}, []byte{10, 0, 0, 1}, "10.0.0.1"},
+		{TypeInfo{ID: TypeTimestamp}, []byte{0, 0, 0x01, 0x54, 0x0e, 0x3c, 0x3c, 0x00}, "2016-04-13 06:08:29.184 +0000 UTC"},
+	}
+	for _, test := range tests {
+		v, err := test.typ.Decode(test.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := toString(v); got != test.want {
+			t.Fatalf("Decode(%v) = %s, want %s", test.data, got, test.want)
+		}
+	}
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case interface {
+		String() string
+	}:
+		return v.String()
+	}
+	return ""
+}
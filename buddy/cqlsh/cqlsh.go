@@ -1,30 +1,98 @@
 package cqlsh
 
 import (
-	"log"
-	"os"
-	"os/exec"
+	"fmt"
+	"sync"
 )
 
+// Cql runs statements against the local node over the native protocol, the
+// connection is opened on first use and reopened after a failure.
 type Cql struct {
+	cfg  *Config
+	conn *Conn
+	mu   sync.Mutex
 }
 
-func NeqCql() *Cql {
-	return &Cql{}
+func NeqCql(cfg *Config) *Cql {
+	return &Cql{cfg: cfg}
 }
 
+// Query runs stmt with the configured consistency level.
+func (cql *Cql) Query(stmt string) (*Result, error) {
+	return cql.QueryConsistency(stmt, cql.cfg.Consistency)
+}
+
+// QueryConsistency runs stmt with the given consistency level. A connection
+// opened before cassandra restarted fails on first use, so stmt is retried once
+// on a new connection after a transport error.
+func (cql *Cql) QueryConsistency(stmt string, consistency Consistency) (*Result, error) {
+	cql.mu.Lock()
+	defer cql.mu.Unlock()
+	result, err := cql.query(stmt, consistency)
+	if _, ok := err.(*Error); err != nil && !ok {
+		result, err = cql.query(stmt, consistency)
+	}
+	return result, err
+}
+
+func (cql *Cql) query(stmt string, consistency Consistency) (*Result, error) {
+	if cql.conn == nil {
+		conn, err := Dial(cql.cfg)
+		if err != nil {
+			return nil, err
+		}
+		cql.conn = conn
+	}
+	result, err := cql.conn.Query(stmt, consistency)
+	if _, ok := err.(*Error); err != nil && !ok {
+		// the connection is in an unknown state after an i/o error
+		cql.conn.Close()
+		cql.conn = nil
+	}
+	return result, err
+}
+
+// Exec runs the statements in order and stops at the first error.
+func (cql *Cql) Exec(stmts []string) error {
+	for _, stmt := range stmts {
+		if _, err := cql.Query(stmt); err != nil {
+			return fmt.Errorf("%v: %s", err, stmt)
+		}
+	}
+	return nil
+}
+
+// Close closes the connection.
+func (cql *Cql) Close() error {
+	cql.mu.Lock()
+	defer cql.mu.Unlock()
+	if cql.conn == nil {
+		return nil
+	}
+	err := cql.conn.Close()
+	cql.conn = nil
+	return err
+}
+
+// DescribeKeyspace returns the CQL that recreates keyspace.
 func (cql *Cql) DescribeKeyspace(keyspace string) (string, error) {
-	args := []string{"-e", "DESCRIBE KEYSPACE " + keyspace}
-	d, err := cql.exec(args)
+	schema, err := cql.Schema()
 	if err != nil {
 		return "", err
 	}
-	return string(d), err
+	ks := schema.Keyspace(keyspace)
+	if ks == nil {
+		return "", fmt.Errorf("Keyspace %s does not exist", keyspace)
+	}
+	return ks.CQL(), nil
 }
 
-func (cql *Cql) exec(args []string) ([]byte, error) {
-	cmd := exec.Command("/usr/local/bin/cqlsh", args...)
-	cmd.Env = os.Environ()
-	log.Printf("cmd: %#v", cmd)
-	return cmd.CombinedOutput()
+// DescribeSchema returns the CQL for all keyspaces, tables, types, functions
+// and materialized views including the system ones.
+func (cql *Cql) DescribeSchema() (string, error) {
+	schema, err := cql.Schema()
+	if err != nil {
+		return "", err
+	}
+	return schema.CQL(), nil
 }
@@ -0,0 +1,281 @@
+package cqlsh
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SchemaDiff describes how the live schema differs from a backed up one. Added
+// means present in the live schema only, dropped means present in the backup only.
+// System keyspaces are not compared.
+type SchemaDiff struct {
+	AddedKeyspaces   []string     `json:"added_keyspaces"`
+	DroppedKeyspaces []string     `json:"dropped_keyspaces"`
+	AddedTables      []string     `json:"added_tables"`
+	DroppedTables    []string     `json:"dropped_tables"`
+	ChangedTables    []*TableDiff `json:"changed_tables"`
+	AddedViews       []string     `json:"added_views"`
+	DroppedViews     []string     `json:"dropped_views"`
+	ChangedViews     []*TableDiff `json:"changed_views"`
+	AddedTypes       []string     `json:"added_types"`
+	DroppedTypes     []string     `json:"dropped_types"`
+	ChangedTypes     []*TypeDiff  `json:"changed_types"`
+	// Unsafe is set when restoring the backup files into the live schema would
+	// lose data or fail, Reasons explains why.
+	Unsafe  bool     `json:"unsafe"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+type TableDiff struct {
+	Keyspace       string             `json:"keyspace"`
+	Table          string             `json:"table"`
+	AddedColumns   []string           `json:"added_columns,omitempty"`
+	DroppedColumns []string           `json:"dropped_columns,omitempty"`
+	ChangedColumns []*Change          `json:"changed_columns,omitempty"`
+	ChangedOptions map[string]*Change `json:"changed_options,omitempty"`
+	Unsafe         bool               `json:"unsafe"`
+	Reasons        []string           `json:"reasons,omitempty"`
+}
+
+// TypeDiff describes the fields of a user defined type that differ.
+type TypeDiff struct {
+	Keyspace      string    `json:"keyspace"`
+	Type          string    `json:"type"`
+	AddedFields   []string  `json:"added_fields,omitempty"`
+	DroppedFields []string  `json:"dropped_fields,omitempty"`
+	ChangedFields []*Change `json:"changed_fields,omitempty"`
+	Unsafe        bool      `json:"unsafe"`
+	Reasons       []string  `json:"reasons,omitempty"`
+}
+
+// Change is a value in the backup and in the live schema.
+type Change struct {
+	Name string `json:"name,omitempty"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Empty reports whether the schemas are the same.
+func (d *SchemaDiff) Empty() bool {
+	return len(d.AddedKeyspaces) == 0 && len(d.DroppedKeyspaces) == 0 && len(d.AddedTables) == 0 &&
+		len(d.DroppedTables) == 0 && len(d.ChangedTables) == 0 && len(d.AddedViews) == 0 &&
+		len(d.DroppedViews) == 0 && len(d.ChangedViews) == 0 && len(d.AddedTypes) == 0 &&
+		len(d.DroppedTypes) == 0 && len(d.ChangedTypes) == 0
+}
+
+func (d *SchemaDiff) unsafe(reason string, args ...interface{}) {
+	d.Unsafe = true
+	d.Reasons = append(d.Reasons, fmt.Sprintf(reason, args...))
+}
+
+func (d *TableDiff) unsafe(reason string, args ...interface{}) {
+	d.Unsafe = true
+	d.Reasons = append(d.Reasons, fmt.Sprintf(reason, args...))
+}
+
+func (d *TypeDiff) unsafe(reason string, args ...interface{}) {
+	d.Unsafe = true
+	d.Reasons = append(d.Reasons, fmt.Sprintf(reason, args...))
+}
+
+// DiffSchema compares a backed up schema with the live one.
+func DiffSchema(backup, live *Schema) *SchemaDiff {
+	d := &SchemaDiff{
+		AddedKeyspaces:   make([]string, 0),
+		DroppedKeyspaces: make([]string, 0),
+		AddedTables:      make([]string, 0),
+		DroppedTables:    make([]string, 0),
+		ChangedTables:    make([]*TableDiff, 0),
+		AddedViews:       make([]string, 0),
+		DroppedViews:     make([]string, 0),
+		ChangedViews:     make([]*TableDiff, 0),
+		AddedTypes:       make([]string, 0),
+		DroppedTypes:     make([]string, 0),
+		ChangedTypes:     make([]*TypeDiff, 0),
+	}
+	for _, ks := range live.Keyspaces {
+		if !IsSystemKeyspace(ks.Name) && backup.Keyspace(ks.Name) == nil {
+			d.AddedKeyspaces = append(d.AddedKeyspaces, ks.Name)
+		}
+	}
+	for _, backupKs := range backup.Keyspaces {
+		if IsSystemKeyspace(backupKs.Name) {
+			continue
+		}
+		liveKs := live.Keyspace(backupKs.Name)
+		if liveKs == nil {
+			d.DroppedKeyspaces = append(d.DroppedKeyspaces, backupKs.Name)
+			d.unsafe("keyspace %s does not exist", backupKs.Name)
+			continue
+		}
+		for _, t := range liveKs.Tables {
+			if backupKs.Table(t.Name) == nil {
+				d.AddedTables = append(d.AddedTables, t.Keyspace+"."+t.Name)
+			}
+		}
+		for _, backupTable := range backupKs.Tables {
+			liveTable := liveKs.Table(backupTable.Name)
+			if liveTable == nil {
+				d.DroppedTables = append(d.DroppedTables, backupTable.Keyspace+"."+backupTable.Name)
+				d.unsafe("table %s.%s does not exist", backupTable.Keyspace, backupTable.Name)
+				continue
+			}
+			if td := diffTable(backupTable, liveTable); td != nil {
+				d.ChangedTables = append(d.ChangedTables, td)
+				if td.Unsafe {
+					d.unsafe("table %s.%s has incompatible changes", td.Keyspace, td.Table)
+				}
+			}
+		}
+		diffViews(d, backupKs, liveKs)
+		diffTypes(d, backupKs, liveKs)
+	}
+	return d
+}
+
+// diffViews compares the materialized views of a keyspace, view sstables are
+// restored like table sstables so a missing view is unsafe too.
+func diffViews(d *SchemaDiff, backupKs, liveKs *Keyspace) {
+	for _, v := range liveKs.Views {
+		if backupKs.View(v.Name) == nil {
+			d.AddedViews = append(d.AddedViews, v.Keyspace+"."+v.Name)
+		}
+	}
+	for _, backupView := range backupKs.Views {
+		liveView := liveKs.View(backupView.Name)
+		if liveView == nil {
+			d.DroppedViews = append(d.DroppedViews, backupView.Keyspace+"."+backupView.Name)
+			d.unsafe("view %s.%s does not exist", backupView.Keyspace, backupView.Name)
+			continue
+		}
+		td := diffTable(&backupView.Table, &liveView.Table)
+		if backupView.BaseTable != liveView.BaseTable || backupView.WhereClause != liveView.WhereClause ||
+			backupView.IncludeAllColumns != liveView.IncludeAllColumns {
+			if td == nil {
+				td = &TableDiff{Keyspace: backupView.Keyspace, Table: backupView.Name}
+			}
+			td.unsafe("view select changed from %s WHERE %s to %s WHERE %s",
+				backupView.BaseTable, backupView.WhereClause, liveView.BaseTable, liveView.WhereClause)
+		}
+		if td != nil {
+			d.ChangedViews = append(d.ChangedViews, td)
+			if td.Unsafe {
+				d.unsafe("view %s.%s has incompatible changes", td.Keyspace, td.Table)
+			}
+		}
+	}
+}
+
+// diffTypes compares the user defined types of a keyspace. Fields added to the
+// live type are safe, values written without them read as null.
+func diffTypes(d *SchemaDiff, backupKs, liveKs *Keyspace) {
+	for _, t := range liveKs.Types {
+		if backupKs.Type(t.Name) == nil {
+			d.AddedTypes = append(d.AddedTypes, t.Keyspace+"."+t.Name)
+		}
+	}
+	for _, backupType := range backupKs.Types {
+		liveType := liveKs.Type(backupType.Name)
+		if liveType == nil {
+			d.DroppedTypes = append(d.DroppedTypes, backupType.Keyspace+"."+backupType.Name)
+			d.unsafe("type %s.%s does not exist", backupType.Keyspace, backupType.Name)
+			continue
+		}
+		if td := diffType(backupType, liveType); td != nil {
+			d.ChangedTypes = append(d.ChangedTypes, td)
+			if td.Unsafe {
+				d.unsafe("type %s.%s has incompatible changes", td.Keyspace, td.Type)
+			}
+		}
+	}
+}
+
+// diffType returns nil when the types are the same. Fields are serialized in
+// order so a field moved to another position is unsafe.
+func diffType(backup, live *Type) *TypeDiff {
+	d := &TypeDiff{Keyspace: backup.Keyspace, Type: backup.Name}
+	for i, name := range backup.FieldNames {
+		if i >= len(live.FieldNames) {
+			d.DroppedFields = append(d.DroppedFields, name)
+			d.unsafe("field %s does not exist", name)
+			continue
+		}
+		if live.FieldNames[i] != name {
+			d.ChangedFields = append(d.ChangedFields, &Change{Name: name, From: name, To: live.FieldNames[i]})
+			d.unsafe("field %d changed from %s to %s", i, name, live.FieldNames[i])
+			continue
+		}
+		if backup.FieldTypes[i] != live.FieldTypes[i] {
+			d.ChangedFields = append(d.ChangedFields, &Change{Name: name, From: backup.FieldTypes[i], To: live.FieldTypes[i]})
+			d.unsafe("field %s changed type from %s to %s", name, backup.FieldTypes[i], live.FieldTypes[i])
+		}
+	}
+	for i := len(backup.FieldNames); i < len(live.FieldNames); i++ {
+		d.AddedFields = append(d.AddedFields, live.FieldNames[i])
+	}
+	if len(d.AddedFields) == 0 && len(d.DroppedFields) == 0 && len(d.ChangedFields) == 0 {
+		return nil
+	}
+	return d
+}
+
+// diffTable returns nil when the tables are the same. Table ids are not compared,
+// restore maps files to the live table id.
+func diffTable(backup, live *Table) *TableDiff {
+	d := &TableDiff{
+		Keyspace:       backup.Keyspace,
+		Table:          backup.Name,
+		ChangedOptions: make(map[string]*Change),
+	}
+	for _, c := range live.Columns {
+		if backup.Column(c.Name) == nil {
+			d.AddedColumns = append(d.AddedColumns, c.Name)
+			if c.Kind == PartitionKey || c.Kind == Clustering {
+				d.unsafe("primary key column %s was added", c.Name)
+			}
+		}
+	}
+	for _, bc := range backup.Columns {
+		lc := live.Column(bc.Name)
+		if lc == nil {
+			d.DroppedColumns = append(d.DroppedColumns, bc.Name)
+			d.unsafe("column %s does not exist", bc.Name)
+			continue
+		}
+		if bc.Type != lc.Type {
+			d.ChangedColumns = append(d.ChangedColumns, &Change{Name: bc.Name, From: bc.Type, To: lc.Type})
+			d.unsafe("column %s changed type from %s to %s", bc.Name, bc.Type, lc.Type)
+		}
+		if bc.Kind != lc.Kind || bc.Position != lc.Position && (bc.Kind == PartitionKey || bc.Kind == Clustering) {
+			from, to := fmt.Sprintf("%s %d", bc.Kind, bc.Position), fmt.Sprintf("%s %d", lc.Kind, lc.Position)
+			d.ChangedColumns = append(d.ChangedColumns, &Change{Name: bc.Name, From: from, To: to})
+			d.unsafe("column %s changed from %s to %s", bc.Name, from, to)
+		}
+		if bc.ClusteringOrder != lc.ClusteringOrder {
+			d.ChangedColumns = append(d.ChangedColumns, &Change{Name: bc.Name, From: bc.ClusteringOrder, To: lc.ClusteringOrder})
+			d.unsafe("column %s changed clustering order", bc.Name)
+		}
+	}
+	if backup.CompactStorage() != live.CompactStorage() {
+		d.unsafe("compact storage changed")
+	}
+	options := make(map[string]bool)
+	for option := range backup.Options {
+		options[option] = true
+	}
+	for option := range live.Options {
+		options[option] = true
+	}
+	for option := range options {
+		if backup.Options[option] != live.Options[option] {
+			d.ChangedOptions[option] = &Change{From: backup.Options[option], To: live.Options[option]}
+		}
+	}
+	if len(d.AddedColumns) == 0 && len(d.DroppedColumns) == 0 && len(d.ChangedColumns) == 0 &&
+		len(d.ChangedOptions) == 0 && !d.Unsafe {
+		return nil
+	}
+	sort.Strings(d.AddedColumns)
+	sort.Strings(d.DroppedColumns)
+	return d
+}
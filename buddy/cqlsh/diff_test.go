@@ -0,0 +1,75 @@
+package cqlsh
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// copySchema deep copies a schema through its json form.
+func copySchema(t *testing.T, s *Schema) *Schema {
+	d, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Schema
+	if err := json.Unmarshal(d, &c); err != nil {
+		t.Fatal(err)
+	}
+	return &c
+}
+
+func TestDiffSchema(t *testing.T) {
+	backup := newSchema(testSchemaRows)
+	live := copySchema(t, backup)
+	if d := DiffSchema(backup, live); !d.Empty() || d.Unsafe {
+		t.Fatalf("expected no differences, got %+v", d)
+	}
+
+	events := live.Keyspace("app").Table("events")
+	events.ID = "7c2d1e40-f6a6-11e5-a8a4-c1d3a4c7e9a1"
+	events.Options["gc_grace_seconds"] = "3600"
+	events.Columns = append(events.Columns, &ColumnDef{Name: "source", Type: "text", Kind: Regular, Position: -1})
+	d := DiffSchema(backup, live)
+	if d.Unsafe || len(d.ChangedTables) != 1 {
+		t.Fatalf("expected a safe change, got %+v", d)
+	}
+	td := d.ChangedTables[0]
+	if len(td.AddedColumns) != 1 || td.ChangedOptions["gc_grace_seconds"].To != "3600" {
+		t.Fatalf("unexpected table diff %+v", td)
+	}
+
+	events.Column("payload").Type = "blob"
+	d = DiffSchema(backup, live)
+	if !d.Unsafe || !d.ChangedTables[0].Unsafe || len(d.ChangedTables[0].ChangedColumns) != 1 {
+		t.Fatalf("expected an unsafe type change, got %+v", d.ChangedTables[0])
+	}
+
+	phone := live.Keyspace("app").Type("phone")
+	phone.FieldNames = append(phone.FieldNames, "kind")
+	phone.FieldTypes = append(phone.FieldTypes, "text")
+	if d = DiffSchema(backup, live); len(d.ChangedTypes) != 1 || d.ChangedTypes[0].Unsafe || len(d.ChangedTypes[0].AddedFields) != 1 {
+		t.Fatalf("expected a safe type change, got %+v", d.ChangedTypes)
+	}
+	phone.FieldTypes[0] = "int"
+	if d = DiffSchema(backup, live); !d.ChangedTypes[0].Unsafe || len(d.ChangedTypes[0].ChangedFields) != 1 {
+		t.Fatalf("expected an unsafe type change, got %+v", d.ChangedTypes[0])
+	}
+
+	view := &View{Table: Table{Keyspace: "app", Name: "events_by_day"}, BaseTable: "events", WhereClause: "day IS NOT NULL"}
+	backup.Keyspace("app").Views = []*View{view}
+	if d = DiffSchema(backup, live); len(d.DroppedViews) != 1 || d.DroppedViews[0] != "app.events_by_day" {
+		t.Fatalf("expected a dropped view, got %+v", d)
+	}
+	liveView := *view
+	liveView.WhereClause = "day IS NOT NULL AND user IS NOT NULL"
+	live.Keyspace("app").Views = []*View{&liveView}
+	if d = DiffSchema(backup, live); len(d.ChangedViews) != 1 || !d.ChangedViews[0].Unsafe {
+		t.Fatalf("expected an unsafe view change, got %+v", d.ChangedViews)
+	}
+
+	live.Keyspace("app").Tables = nil
+	d = DiffSchema(backup, live)
+	if !d.Unsafe || len(d.DroppedTables) != 1 || d.DroppedTables[0] != "app.events" {
+		t.Fatalf("expected a dropped table, got %+v", d)
+	}
+}
@@ -0,0 +1,199 @@
+package cqlsh
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// native protocol opcodes
+const (
+	opError         byte = 0x00
+	opStartup       byte = 0x01
+	opReady         byte = 0x02
+	opAuthenticate  byte = 0x03
+	opQuery         byte = 0x07
+	opResult        byte = 0x08
+	opAuthChallenge byte = 0x0E
+	opAuthResponse  byte = 0x0F
+	opAuthSuccess   byte = 0x10
+)
+
+// response header flags
+const (
+	flagTracing       byte = 0x02
+	flagCustomPayload byte = 0x04
+	flagWarning       byte = 0x08
+)
+
+const headerSize = 9
+const maxFrameSize = 256 * 1024 * 1024
+
+var errShortBuffer = errors.New("cql: frame body too short")
+
+type frame struct {
+	version byte
+	flags   byte
+	stream  int16
+	opcode  byte
+	body    []byte
+}
+
+func writeFrame(w io.Writer, f *frame) error {
+	header := make([]byte, headerSize, headerSize+len(f.body))
+	header[0] = f.version
+	header[1] = f.flags
+	binary.BigEndian.PutUint16(header[2:], uint16(f.stream))
+	header[4] = f.opcode
+	binary.BigEndian.PutUint32(header[5:], uint32(len(f.body)))
+	_, err := w.Write(append(header, f.body...))
+	return err
+}
+
+func readFrame(r io.Reader) (*frame, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	f := &frame{
+		version: header[0] & 0x7F,
+		flags:   header[1],
+		stream:  int16(binary.BigEndian.Uint16(header[2:])),
+		opcode:  header[4],
+	}
+	length := binary.BigEndian.Uint32(header[5:])
+	if length > maxFrameSize {
+		return nil, fmt.Errorf("cql: frame of %d bytes exceeds limit", length)
+	}
+	f.body = make([]byte, length)
+	if _, err := io.ReadFull(r, f.body); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// encoder builds frame bodies out of the protocol notation types.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) byte(b byte) {
+	e.buf = append(e.buf, b)
+}
+
+func (e *encoder) short(n uint16) {
+	e.buf = append(e.buf, byte(n>>8), byte(n))
+}
+
+func (e *encoder) int(n int32) {
+	e.buf = append(e.buf, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func (e *encoder) string(s string) {
+	e.short(uint16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) longString(s string) {
+	e.int(int32(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) bytes(b []byte) {
+	if b == nil {
+		e.int(-1)
+		return
+	}
+	e.int(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) stringMap(m map[string]string) {
+	e.short(uint16(len(m)))
+	for k, v := range m {
+		e.string(k)
+		e.string(v)
+	}
+}
+
+// decoder reads the protocol notation types, the first error sticks and
+// every read after it returns zero values.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.buf) < n {
+		d.err = errShortBuffer
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) byte() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) short() uint16 {
+	if b := d.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) int() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	return string(d.next(int(d.short())))
+}
+
+func (d *decoder) longString() string {
+	return string(d.next(int(d.int())))
+}
+
+func (d *decoder) stringList() []string {
+	n := int(d.short())
+	list := make([]string, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		list = append(list, d.string())
+	}
+	return list
+}
+
+// bytes returns nil for a null value.
+func (d *decoder) bytes() []byte {
+	n := d.int()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+func (d *decoder) shortBytes() []byte {
+	return d.next(int(d.short()))
+}
+
+func (d *decoder) bytesMap() map[string][]byte {
+	n := int(d.short())
+	m := make(map[string][]byte, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		k := d.string()
+		m[k] = d.bytes()
+	}
+	return m
+}
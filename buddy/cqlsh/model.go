@@ -0,0 +1,445 @@
+package cqlsh
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema is the cluster schema as stored in the system_schema keyspace.
+type Schema struct {
+	Keyspaces []*Keyspace `json:"keyspaces"`
+}
+
+type Keyspace struct {
+	Name          string            `json:"name"`
+	DurableWrites bool              `json:"durable_writes"`
+	Replication   map[string]string `json:"replication"`
+	Types         []*Type           `json:"types,omitempty"`
+	Tables        []*Table          `json:"tables,omitempty"`
+	Views         []*View           `json:"views,omitempty"`
+	Functions     []*Function       `json:"functions,omitempty"`
+	Aggregates    []*Aggregate      `json:"aggregates,omitempty"`
+}
+
+// Type is a user defined type.
+type Type struct {
+	Keyspace   string   `json:"keyspace"`
+	Name       string   `json:"name"`
+	FieldNames []string `json:"field_names"`
+	FieldTypes []string `json:"field_types"`
+}
+
+// column kinds
+const (
+	PartitionKey = "partition_key"
+	Clustering   = "clustering"
+	Regular      = "regular"
+	Static       = "static"
+)
+
+type ColumnDef struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Kind is one of PartitionKey, Clustering, Regular or Static.
+	Kind string `json:"kind"`
+	// Position orders the partition key and clustering columns.
+	Position int `json:"position"`
+	// ClusteringOrder is asc or desc for clustering columns.
+	ClusteringOrder string `json:"clustering_order,omitempty"`
+}
+
+type Table struct {
+	Keyspace string       `json:"keyspace"`
+	Name     string       `json:"name"`
+	ID       string       `json:"id"`
+	Flags    []string     `json:"flags,omitempty"`
+	Columns  []*ColumnDef `json:"columns"`
+	// Options maps table options to their CQL literal values.
+	Options map[string]string `json:"options"`
+	Indexes []*Index          `json:"indexes,omitempty"`
+}
+
+type Index struct {
+	Name    string            `json:"name"`
+	Kind    string            `json:"kind"`
+	Options map[string]string `json:"options"`
+}
+
+// View is a materialized view, its columns and options are described like a table's.
+type View struct {
+	Table
+	BaseTable         string `json:"base_table"`
+	IncludeAllColumns bool   `json:"include_all_columns"`
+	WhereClause       string `json:"where_clause"`
+}
+
+type Function struct {
+	Keyspace          string   `json:"keyspace"`
+	Name              string   `json:"name"`
+	ArgumentNames     []string `json:"argument_names"`
+	ArgumentTypes     []string `json:"argument_types"`
+	ReturnType        string   `json:"return_type"`
+	Language          string   `json:"language"`
+	Body              string   `json:"body"`
+	CalledOnNullInput bool     `json:"called_on_null_input"`
+}
+
+type Aggregate struct {
+	Keyspace      string   `json:"keyspace"`
+	Name          string   `json:"name"`
+	ArgumentTypes []string `json:"argument_types"`
+	StateFunc     string   `json:"state_func"`
+	StateType     string   `json:"state_type"`
+	FinalFunc     string   `json:"final_func,omitempty"`
+	InitCond      string   `json:"initcond,omitempty"`
+	ReturnType    string   `json:"return_type"`
+}
+
+// schemaTables are the system_schema tables the model is read from.
+var schemaTables = []string{"keyspaces", "types", "tables", "columns", "indexes", "views", "functions", "aggregates"}
+
+// Schema reads the schema model from the system_schema tables of the node.
+func (cql *Cql) Schema() (*Schema, error) {
+	results := make(map[string][]Row)
+	for _, table := range schemaTables {
+		result, err := cql.QueryConsistency("SELECT * FROM system_schema."+table, LocalOne)
+		if err != nil {
+			return nil, err
+		}
+		results[table] = result.Rows
+	}
+	return newSchema(results), nil
+}
+
+// TableIDs maps keyspace.table to the table id for every table and
+// materialized view, their data directories are named after the id.
+func (s *Schema) TableIDs() map[string]string {
+	ids := make(map[string]string)
+	for _, ks := range s.Keyspaces {
+		for _, t := range ks.Tables {
+			ids[ks.Name+"."+t.Name] = t.ID
+		}
+		for _, v := range ks.Views {
+			ids[ks.Name+"."+v.Name] = v.ID
+		}
+	}
+	return ids
+}
+
+// newSchema builds the model from system_schema rows keyed by table name.
+func newSchema(rows map[string][]Row) *Schema {
+	s := &Schema{Keyspaces: make([]*Keyspace, 0)}
+	for _, row := range rows["keyspaces"] {
+		s.Keyspaces = append(s.Keyspaces, &Keyspace{
+			Name:          row.String("keyspace_name"),
+			DurableWrites: row.Bool("durable_writes"),
+			Replication:   row.StringMap("replication"),
+		})
+	}
+	sort.Slice(s.Keyspaces, func(i, j int) bool { return s.Keyspaces[i].Name < s.Keyspaces[j].Name })
+
+	for _, row := range rows["types"] {
+		if ks := s.Keyspace(row.String("keyspace_name")); ks != nil {
+			ks.Types = append(ks.Types, &Type{
+				Keyspace:   ks.Name,
+				Name:       row.String("type_name"),
+				FieldNames: row.Strings("field_names"),
+				FieldTypes: row.Strings("field_types"),
+			})
+		}
+	}
+	for _, row := range rows["tables"] {
+		if ks := s.Keyspace(row.String("keyspace_name")); ks != nil {
+			t := newTable(ks.Name, row.String("table_name"), row)
+			ks.Tables = append(ks.Tables, t)
+		}
+	}
+	for _, row := range rows["views"] {
+		if ks := s.Keyspace(row.String("keyspace_name")); ks != nil {
+			ks.Views = append(ks.Views, &View{
+				Table:             *newTable(ks.Name, row.String("view_name"), row),
+				BaseTable:         row.String("base_table_name"),
+				IncludeAllColumns: row.Bool("include_all_columns"),
+				WhereClause:       row.String("where_clause"),
+			})
+		}
+	}
+	for _, row := range rows["columns"] {
+		ks := s.Keyspace(row.String("keyspace_name"))
+		if ks == nil {
+			continue
+		}
+		t := ks.Table(row.String("table_name"))
+		if t == nil {
+			if v := ks.View(row.String("table_name")); v != nil {
+				t = &v.Table
+			}
+		}
+		if t == nil {
+			continue
+		}
+		order := row.String("clustering_order")
+		if order == "none" {
+			order = ""
+		}
+		t.Columns = append(t.Columns, &ColumnDef{
+			Name:            row.String("column_name"),
+			Type:            row.String("type"),
+			Kind:            row.String("kind"),
+			Position:        int(row.Int("position")),
+			ClusteringOrder: order,
+		})
+	}
+	for _, row := range rows["indexes"] {
+		ks := s.Keyspace(row.String("keyspace_name"))
+		if ks == nil {
+			continue
+		}
+		if t := ks.Table(row.String("table_name")); t != nil {
+			t.Indexes = append(t.Indexes, &Index{
+				Name:    row.String("index_name"),
+				Kind:    row.String("kind"),
+				Options: row.StringMap("options"),
+			})
+		}
+	}
+	for _, row := range rows["functions"] {
+		if ks := s.Keyspace(row.String("keyspace_name")); ks != nil {
+			ks.Functions = append(ks.Functions, &Function{
+				Keyspace:          ks.Name,
+				Name:              row.String("function_name"),
+				ArgumentNames:     row.Strings("argument_names"),
+				ArgumentTypes:     row.Strings("argument_types"),
+				ReturnType:        row.String("return_type"),
+				Language:          row.String("language"),
+				Body:              row.String("body"),
+				CalledOnNullInput: row.Bool("called_on_null_input"),
+			})
+		}
+	}
+	for _, row := range rows["aggregates"] {
+		if ks := s.Keyspace(row.String("keyspace_name")); ks != nil {
+			ks.Aggregates = append(ks.Aggregates, &Aggregate{
+				Keyspace:      ks.Name,
+				Name:          row.String("aggregate_name"),
+				ArgumentTypes: row.Strings("argument_types"),
+				StateFunc:     row.String("state_func"),
+				StateType:     row.String("state_type"),
+				FinalFunc:     row.String("final_func"),
+				InitCond:      row.String("initcond"),
+				ReturnType:    row.String("return_type"),
+			})
+		}
+	}
+	for _, ks := range s.Keyspaces {
+		ks.sort()
+	}
+	return s
+}
+
+// nonOptionColumns are system_schema.tables and views columns that are not table options.
+var nonOptionColumns = map[string]bool{
+	"keyspace_name":       true,
+	"table_name":          true,
+	"view_name":           true,
+	"id":                  true,
+	"flags":               true,
+	"extensions":          true,
+	"base_table_id":       true,
+	"base_table_name":     true,
+	"include_all_columns": true,
+	"where_clause":        true,
+}
+
+func newTable(keyspace, name string, row Row) *Table {
+	t := &Table{
+		Keyspace: keyspace,
+		Name:     name,
+		ID:       fmt.Sprint(row["id"]),
+		Flags:    row.Strings("flags"),
+		Columns:  make([]*ColumnDef, 0),
+		Options:  make(map[string]string),
+	}
+	for option, v := range row {
+		if nonOptionColumns[option] || v == nil {
+			continue
+		}
+		t.Options[option] = literal(v)
+	}
+	return t
+}
+
+func (ks *Keyspace) sort() {
+	sort.Slice(ks.Tables, func(i, j int) bool { return ks.Tables[i].Name < ks.Tables[j].Name })
+	sort.Slice(ks.Views, func(i, j int) bool { return ks.Views[i].Name < ks.Views[j].Name })
+	sort.Slice(ks.Functions, func(i, j int) bool { return ks.Functions[i].Name < ks.Functions[j].Name })
+	sort.Slice(ks.Aggregates, func(i, j int) bool { return ks.Aggregates[i].Name < ks.Aggregates[j].Name })
+	ks.Types = sortTypes(ks.Types)
+	for _, t := range ks.Tables {
+		t.sort()
+	}
+	for _, v := range ks.Views {
+		v.sort()
+	}
+}
+
+// sortTypes orders types by name with the types a type refers to before it.
+func sortTypes(types []*Type) []*Type {
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	sorted := make([]*Type, 0, len(types))
+	done := make(map[string]bool)
+	var visit func(t *Type)
+	visit = func(t *Type) {
+		if done[t.Name] {
+			return
+		}
+		done[t.Name] = true
+		for _, dep := range types {
+			for _, ft := range t.FieldTypes {
+				if dep != t && referencesType(ft, dep.Name) {
+					visit(dep)
+				}
+			}
+		}
+		sorted = append(sorted, t)
+	}
+	for _, t := range types {
+		visit(t)
+	}
+	return sorted
+}
+
+func referencesType(cqlType, name string) bool {
+	split := func(r rune) bool { return r == '<' || r == '>' || r == ',' || r == ' ' }
+	for _, part := range strings.FieldsFunc(cqlType, split) {
+		if part == name || part == quoteName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Table) sort() {
+	sort.SliceStable(t.Columns, func(i, j int) bool {
+		a, b := t.Columns[i], t.Columns[j]
+		if kindOrder[a.Kind] != kindOrder[b.Kind] {
+			return kindOrder[a.Kind] < kindOrder[b.Kind]
+		}
+		if a.Kind == PartitionKey || a.Kind == Clustering {
+			return a.Position < b.Position
+		}
+		return a.Name < b.Name
+	})
+	sort.Slice(t.Indexes, func(i, j int) bool { return t.Indexes[i].Name < t.Indexes[j].Name })
+}
+
+var kindOrder = map[string]int{PartitionKey: 0, Clustering: 1, Static: 2, Regular: 2}
+
+// Keyspace returns the named keyspace or nil.
+func (s *Schema) Keyspace(name string) *Keyspace {
+	for _, ks := range s.Keyspaces {
+		if ks.Name == name {
+			return ks
+		}
+	}
+	return nil
+}
+
+// Table returns the named table or nil.
+func (ks *Keyspace) Table(name string) *Table {
+	for _, t := range ks.Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// View returns the named materialized view or nil.
+func (ks *Keyspace) View(name string) *View {
+	for _, v := range ks.Views {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// Type returns the named user defined type or nil.
+func (ks *Keyspace) Type(name string) *Type {
+	for _, t := range ks.Types {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Column returns the named column or nil.
+func (t *Table) Column(name string) *ColumnDef {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// PartitionKey returns the partition key columns in order.
+func (t *Table) PartitionKey() []*ColumnDef {
+	return t.columns(PartitionKey)
+}
+
+// ClusteringColumns returns the clustering columns in order.
+func (t *Table) ClusteringColumns() []*ColumnDef {
+	return t.columns(Clustering)
+}
+
+func (t *Table) columns(kind string) []*ColumnDef {
+	cols := make([]*ColumnDef, 0)
+	for _, c := range t.Columns {
+		if c.Kind == kind {
+			cols = append(cols, c)
+		}
+	}
+	sort.SliceStable(cols, func(i, j int) bool { return cols[i].Position < cols[j].Position })
+	return cols
+}
+
+// literal formats a decoded value as a CQL literal.
+func literal(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return "'" + strings.Replace(v, "'", "''", -1) + "'"
+	case float64:
+		if math.Trunc(v) == v && math.Abs(v) < 1e15 {
+			return strconv.FormatFloat(v, 'f', 1, 64)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case float32:
+		return literal(float64(v))
+	case []byte:
+		return fmt.Sprintf("0x%x", v)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, literal(k)+": "+literal(v[k]))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, e := range v {
+			parts = append(parts, literal(e))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	return fmt.Sprint(v)
+}
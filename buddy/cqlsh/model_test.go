@@ -0,0 +1,85 @@
+package cqlsh
+
+import (
+	"strings"
+	"testing"
+)
+
+var testSchemaRows = map[string][]Row{
+	"keyspaces": {
+		{"keyspace_name": "app", "durable_writes": true, "replication": map[string]interface{}{
+			"class": "org.apache.cassandra.locator.NetworkTopologyStrategy", "us-west": "3"}},
+	},
+	"types": {
+		{"keyspace_name": "app", "type_name": "phone", "field_names": []interface{}{"number"}, "field_types": []interface{}{"text"}},
+		{"keyspace_name": "app", "type_name": "address", "field_names": []interface{}{"street", "phones"},
+			"field_types": []interface{}{"text", "frozen<list<frozen<phone>>>"}},
+	},
+	"tables": {
+		{"keyspace_name": "app", "table_name": "events", "id": "5b1a7e20-f6a6-11e5-a8a4-c1d3a4c7e9a1",
+			"flags": []interface{}{"compound"}, "comment": "user's events", "gc_grace_seconds": int32(864000),
+			"bloom_filter_fp_chance": float64(0.01), "crc_check_chance": float64(1),
+			"compaction": map[string]interface{}{"class": "org.apache.cassandra.db.compaction.SizeTieredCompactionStrategy"}},
+	},
+	"columns": {
+		{"keyspace_name": "app", "table_name": "events", "column_name": "time", "kind": "clustering", "position": int32(0), "type": "timeuuid", "clustering_order": "desc"},
+		{"keyspace_name": "app", "table_name": "events", "column_name": "user", "kind": "partition_key", "position": int32(0), "type": "uuid", "clustering_order": "none"},
+		{"keyspace_name": "app", "table_name": "events", "column_name": "day", "kind": "partition_key", "position": int32(1), "type": "date", "clustering_order": "none"},
+		{"keyspace_name": "app", "table_name": "events", "column_name": "payload", "kind": "regular", "position": int32(-1), "type": "text", "clustering_order": "none"},
+		{"keyspace_name": "app", "table_name": "events", "column_name": "Select", "kind": "static", "position": int32(-1), "type": "frozen<address>", "clustering_order": "none"},
+	},
+	"indexes": {
+		{"keyspace_name": "app", "table_name": "events", "index_name": "events_payload_idx", "kind": "COMPOSITES", "options": map[string]interface{}{"target": "payload"}},
+	},
+}
+
+func TestNewSchema(t *testing.T) {
+	s := newSchema(testSchemaRows)
+	ks := s.Keyspace("app")
+	if ks == nil {
+		t.Fatal("missing keyspace")
+	}
+	if ks.Types[0].Name != "phone" {
+		t.Fatal("types are not ordered by dependency")
+	}
+	events := ks.Table("events")
+	if events == nil {
+		t.Fatal("missing table")
+	}
+	if pk := events.PartitionKey(); len(pk) != 2 || pk[0].Name != "user" || pk[1].Name != "day" {
+		t.Fatalf("unexpected partition key %+v", pk)
+	}
+	if events.Options["comment"] != "'user''s events'" || events.Options["crc_check_chance"] != "1.0" {
+		t.Fatalf("unexpected options %+v", events.Options)
+	}
+	if ids := s.TableIDs(); ids["app.events"] != "5b1a7e20-f6a6-11e5-a8a4-c1d3a4c7e9a1" {
+		t.Fatalf("unexpected table ids %+v", ids)
+	}
+
+	cql := events.CreateStatement()
+	want := `CREATE TABLE app.events (
+    user uuid,
+    day date,
+    time timeuuid,
+    "Select" frozen<address> static,
+    payload text,
+    PRIMARY KEY ((user, day), time)
+) WITH CLUSTERING ORDER BY (time DESC)
+    AND bloom_filter_fp_chance = 0.01
+    AND comment = 'user''s events'
+    AND compaction = {'class': 'org.apache.cassandra.db.compaction.SizeTieredCompactionStrategy'}
+    AND crc_check_chance = 1.0
+    AND gc_grace_seconds = 864000`
+	if cql != want {
+		t.Fatalf("unexpected table cql:\n%s", cql)
+	}
+
+	schema := s.CQL()
+	stmts := SplitStatements(schema)
+	if len(stmts) != 5 {
+		t.Fatalf("expected 5 statements, got %d:\n%s", len(stmts), schema)
+	}
+	if !strings.HasPrefix(stmts[4], "CREATE INDEX events_payload_idx ON app.events (payload)") {
+		t.Fatalf("unexpected index statement %q", stmts[4])
+	}
+}
@@ -0,0 +1,235 @@
+package cqlsh
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var plainNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// reservedWords need quoting when used as names.
+var reservedWords = map[string]bool{
+	"add": true, "allow": true, "alter": true, "and": true, "apply": true, "asc": true,
+	"authorize": true, "batch": true, "begin": true, "by": true, "columnfamily": true,
+	"create": true, "delete": true, "desc": true, "describe": true, "drop": true,
+	"entries": true, "execute": true, "from": true, "full": true, "grant": true,
+	"if": true, "in": true, "index": true, "infinity": true, "insert": true, "into": true,
+	"is": true, "keyspace": true, "limit": true, "materialized": true, "mbean": true,
+	"mbeans": true, "modify": true, "nan": true, "norecursive": true, "not": true,
+	"null": true, "of": true, "on": true, "or": true, "order": true, "primary": true,
+	"rename": true, "replace": true, "revoke": true, "schema": true, "select": true,
+	"set": true, "table": true, "to": true, "token": true, "truncate": true,
+	"unlogged": true, "unset": true, "update": true, "use": true, "using": true,
+	"view": true, "where": true, "with": true,
+}
+
+// quoteName quotes a keyspace, table or column name when cassandra would
+// otherwise lowercase it or parse it as a keyword.
+func quoteName(name string) string {
+	if plainNameRegex.MatchString(name) && !reservedWords[name] {
+		return name
+	}
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func qualifiedName(keyspace, name string) string {
+	return quoteName(keyspace) + "." + quoteName(name)
+}
+
+// CQL returns the statements that recreate the schema, each terminated by a
+// semicolon, in an order that satisfies their dependencies.
+func (s *Schema) CQL() string {
+	parts := make([]string, 0, len(s.Keyspaces))
+	for _, ks := range s.Keyspaces {
+		parts = append(parts, ks.CQL())
+	}
+	return strings.Join(parts, "\n")
+}
+
+// CQL returns the statements that recreate the keyspace and everything in it.
+func (ks *Keyspace) CQL() string {
+	stmts := []string{ks.CreateStatement()}
+	for _, t := range ks.Types {
+		stmts = append(stmts, t.CreateStatement())
+	}
+	for _, f := range ks.Functions {
+		stmts = append(stmts, f.CreateStatement())
+	}
+	for _, a := range ks.Aggregates {
+		stmts = append(stmts, a.CreateStatement())
+	}
+	for _, t := range ks.Tables {
+		stmts = append(stmts, t.CreateStatement())
+		for _, idx := range t.Indexes {
+			stmts = append(stmts, idx.CreateStatement(t))
+		}
+	}
+	for _, v := range ks.Views {
+		stmts = append(stmts, v.CreateStatement())
+	}
+	return strings.Join(stmts, ";\n\n") + ";\n"
+}
+
+func (ks *Keyspace) CreateStatement() string {
+	replication := make(map[string]interface{}, len(ks.Replication))
+	for k, v := range ks.Replication {
+		replication[k] = v
+	}
+	return fmt.Sprintf("CREATE KEYSPACE %s WITH replication = %s AND durable_writes = %t",
+		quoteName(ks.Name), literal(replication), ks.DurableWrites)
+}
+
+func (t *Type) CreateStatement() string {
+	fields := make([]string, 0, len(t.FieldNames))
+	for i, name := range t.FieldNames {
+		fields = append(fields, fmt.Sprintf("    %s %s", quoteName(name), t.FieldTypes[i]))
+	}
+	return fmt.Sprintf("CREATE TYPE %s (\n%s\n)", qualifiedName(t.Keyspace, t.Name), strings.Join(fields, ",\n"))
+}
+
+func (f *Function) CreateStatement() string {
+	args := make([]string, 0, len(f.ArgumentNames))
+	for i, name := range f.ArgumentNames {
+		args = append(args, quoteName(name)+" "+f.ArgumentTypes[i])
+	}
+	onNull := "RETURNS NULL ON NULL INPUT"
+	if f.CalledOnNullInput {
+		onNull = "CALLED ON NULL INPUT"
+	}
+	return fmt.Sprintf("CREATE FUNCTION %s(%s)\n    %s\n    RETURNS %s\n    LANGUAGE %s\n    AS $$%s$$",
+		qualifiedName(f.Keyspace, f.Name), strings.Join(args, ", "), onNull, f.ReturnType, f.Language, f.Body)
+}
+
+func (a *Aggregate) CreateStatement() string {
+	stmt := fmt.Sprintf("CREATE AGGREGATE %s(%s)\n    SFUNC %s\n    STYPE %s",
+		qualifiedName(a.Keyspace, a.Name), strings.Join(a.ArgumentTypes, ", "), quoteName(a.StateFunc), a.StateType)
+	if a.FinalFunc != "" {
+		stmt += "\n    FINALFUNC " + quoteName(a.FinalFunc)
+	}
+	if a.InitCond != "" {
+		stmt += "\n    INITCOND " + a.InitCond
+	}
+	return stmt
+}
+
+// CompactStorage reports whether the table was created WITH COMPACT STORAGE.
+func (t *Table) CompactStorage() bool {
+	compound := false
+	for _, flag := range t.Flags {
+		switch flag {
+		case "dense", "super":
+			return true
+		case "compound":
+			compound = true
+		}
+	}
+	return len(t.Flags) > 0 && !compound
+}
+
+func (t *Table) CreateStatement() string {
+	cols := make([]string, 0, len(t.Columns)+1)
+	for _, c := range t.Columns {
+		// compact tables have a hidden value column without a name
+		if c.Name == "" || c.Type == "empty" {
+			continue
+		}
+		col := fmt.Sprintf("    %s %s", quoteName(c.Name), c.Type)
+		if c.Kind == Static {
+			col += " static"
+		}
+		cols = append(cols, col)
+	}
+	cols = append(cols, "    PRIMARY KEY ("+t.primaryKey()+")")
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n) WITH %s",
+		qualifiedName(t.Keyspace, t.Name), strings.Join(cols, ",\n"), t.with())
+}
+
+func (t *Table) primaryKey() string {
+	pk := make([]string, 0)
+	for _, c := range t.PartitionKey() {
+		pk = append(pk, quoteName(c.Name))
+	}
+	key := strings.Join(pk, ", ")
+	if len(pk) > 1 {
+		key = "(" + key + ")"
+	}
+	for _, c := range t.ClusteringColumns() {
+		key += ", " + quoteName(c.Name)
+	}
+	return key
+}
+
+// with renders the table properties.
+func (t *Table) with() string {
+	props := make([]string, 0, len(t.Options)+2)
+	if t.CompactStorage() {
+		props = append(props, "COMPACT STORAGE")
+	}
+	order := make([]string, 0)
+	for _, c := range t.ClusteringColumns() {
+		if c.ClusteringOrder != "" {
+			order = append(order, quoteName(c.Name)+" "+strings.ToUpper(c.ClusteringOrder))
+		}
+	}
+	if len(order) > 0 {
+		props = append(props, "CLUSTERING ORDER BY ("+strings.Join(order, ", ")+")")
+	}
+	options := make([]string, 0, len(t.Options))
+	for option := range t.Options {
+		options = append(options, option)
+	}
+	sort.Strings(options)
+	for _, option := range options {
+		props = append(props, option+" = "+t.Options[option])
+	}
+	return strings.Join(props, "\n    AND ")
+}
+
+func (idx *Index) CreateStatement(t *Table) string {
+	target := idx.Options["target"]
+	if idx.Kind != "CUSTOM" {
+		return fmt.Sprintf("CREATE INDEX %s ON %s (%s)", quoteName(idx.Name), qualifiedName(t.Keyspace, t.Name), target)
+	}
+	stmt := fmt.Sprintf("CREATE CUSTOM INDEX %s ON %s (%s) USING %s",
+		quoteName(idx.Name), qualifiedName(t.Keyspace, t.Name), target, literal(idx.Options["class_name"]))
+	options := make(map[string]interface{})
+	for k, v := range idx.Options {
+		if k != "target" && k != "class_name" {
+			options[k] = v
+		}
+	}
+	if len(options) > 0 {
+		stmt += " WITH OPTIONS = " + literal(options)
+	}
+	return stmt
+}
+
+func (v *View) CreateStatement() string {
+	selected := "*"
+	if !v.IncludeAllColumns {
+		cols := make([]string, 0, len(v.Columns))
+		for _, c := range v.Columns {
+			cols = append(cols, quoteName(c.Name))
+		}
+		selected = strings.Join(cols, ", ")
+	}
+	return fmt.Sprintf("CREATE MATERIALIZED VIEW %s AS\n    SELECT %s\n    FROM %s\n    WHERE %s\n    PRIMARY KEY (%s)\n    WITH %s",
+		qualifiedName(v.Keyspace, v.Name), selected, qualifiedName(v.Keyspace, v.BaseTable),
+		v.WhereClause, v.viewPrimaryKey(), v.with())
+}
+
+// viewPrimaryKey always parenthesizes the partition key, a single column view
+// key followed by clustering columns reads ambiguously otherwise.
+func (v *View) viewPrimaryKey() string {
+	pk := make([]string, 0)
+	for _, c := range v.PartitionKey() {
+		pk = append(pk, quoteName(c.Name))
+	}
+	key := "(" + strings.Join(pk, ", ") + ")"
+	for _, c := range v.ClusteringColumns() {
+		key += ", " + quoteName(c.Name)
+	}
+	return key
+}
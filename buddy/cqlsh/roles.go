@@ -0,0 +1,171 @@
+package cqlsh
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Role is a role from system_auth with its grants.
+type Role struct {
+	Name      string `json:"name"`
+	Login     bool   `json:"login"`
+	Superuser bool   `json:"superuser"`
+	// SaltedHash is the password hash, it is only exported on request and is
+	// restored as is so passwords keep working.
+	SaltedHash  string        `json:"salted_hash,omitempty"`
+	MemberOf    []string      `json:"member_of,omitempty"`
+	Permissions []*Permission `json:"permissions,omitempty"`
+}
+
+// Permission is a set of permissions on a resource like data/keyspace/table.
+type Permission struct {
+	Resource    string   `json:"resource"`
+	Permissions []string `json:"permissions"`
+}
+
+// Roles reads roles, role hierarchy and permissions from system_auth, with
+// hashes the password hashes as well.
+func (cql *Cql) Roles(hashes bool) ([]*Role, error) {
+	stmt := "SELECT role, can_login, is_superuser, member_of FROM system_auth.roles"
+	if hashes {
+		stmt = "SELECT role, can_login, is_superuser, member_of, salted_hash FROM system_auth.roles"
+	}
+	result, err := cql.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]*Role, 0, len(result.Rows))
+	byName := make(map[string]*Role)
+	for _, row := range result.Rows {
+		role := &Role{
+			Name:       row.String("role"),
+			Login:      row.Bool("can_login"),
+			Superuser:  row.Bool("is_superuser"),
+			SaltedHash: row.String("salted_hash"),
+			MemberOf:   row.Strings("member_of"),
+		}
+		sort.Strings(role.MemberOf)
+		roles = append(roles, role)
+		byName[role.Name] = role
+	}
+	result, err = cql.Query("SELECT role, resource, permissions FROM system_auth.role_permissions")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range result.Rows {
+		if role, ok := byName[row.String("role")]; ok {
+			perms := row.Strings("permissions")
+			sort.Strings(perms)
+			role.Permissions = append(role.Permissions, &Permission{Resource: row.String("resource"), Permissions: perms})
+		}
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	for _, role := range roles {
+		sort.Slice(role.Permissions, func(i, j int) bool { return role.Permissions[i].Resource < role.Permissions[j].Resource })
+	}
+	return roles, nil
+}
+
+// SelectRoles returns the named roles and the roles they are members of, all
+// roles when names is empty.
+func SelectRoles(roles []*Role, names []string) []*Role {
+	if len(names) == 0 {
+		return roles
+	}
+	byName := make(map[string]*Role)
+	for _, role := range roles {
+		byName[role.Name] = role
+	}
+	selected := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		role, ok := byName[name]
+		if !ok || selected[name] {
+			return
+		}
+		selected[name] = true
+		for _, parent := range role.MemberOf {
+			visit(parent)
+		}
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	result := make([]*Role, 0, len(selected))
+	for _, role := range roles {
+		if selected[role.Name] {
+			result = append(result, role)
+		}
+	}
+	return result
+}
+
+// RoleStatements returns the statements that recreate roles, their passwords,
+// memberships and grants. Roles are created before any membership is granted.
+// CQL only takes plain text passwords so hashes are written directly into
+// system_auth.roles, which needs a superuser and bypasses the role manager.
+func RoleStatements(roles []*Role) []string {
+	stmts := make([]string, 0)
+	for _, role := range roles {
+		stmts = append(stmts, fmt.Sprintf("CREATE ROLE IF NOT EXISTS %s WITH LOGIN = %t AND SUPERUSER = %t",
+			quoteName(role.Name), role.Login, role.Superuser))
+		if role.SaltedHash != "" {
+			stmts = append(stmts, fmt.Sprintf("UPDATE system_auth.roles SET salted_hash = %s WHERE role = %s",
+				literal(role.SaltedHash), literal(role.Name)))
+		}
+	}
+	for _, role := range roles {
+		for _, parent := range role.MemberOf {
+			stmts = append(stmts, fmt.Sprintf("GRANT %s TO %s", quoteName(parent), quoteName(role.Name)))
+		}
+		for _, perm := range role.Permissions {
+			resource, ok := resourceName(perm.Resource)
+			if !ok {
+				log.Println("Skipping grants on unsupported resource", perm.Resource, "for", role.Name)
+				continue
+			}
+			for _, p := range perm.Permissions {
+				stmts = append(stmts, fmt.Sprintf("GRANT %s ON %s TO %s", p, resource, quoteName(role.Name)))
+			}
+		}
+	}
+	return stmts
+}
+
+// RolesCQL renders roles as a CQL script.
+func RolesCQL(roles []*Role) string {
+	stmts := RoleStatements(roles)
+	if len(stmts) == 0 {
+		return ""
+	}
+	return strings.Join(stmts, ";\n") + ";\n"
+}
+
+// resourceName converts a system_auth resource to its CQL form, data/ks/table
+// becomes TABLE ks.table.
+func resourceName(resource string) (string, bool) {
+	parts := strings.Split(resource, "/")
+	switch {
+	case parts[0] == "data" && len(parts) == 1:
+		return "ALL KEYSPACES", true
+	case parts[0] == "data" && len(parts) == 2:
+		return "KEYSPACE " + quoteName(parts[1]), true
+	case parts[0] == "data" && len(parts) == 3:
+		return "TABLE " + qualifiedName(parts[1], parts[2]), true
+	case parts[0] == "roles" && len(parts) == 1:
+		return "ALL ROLES", true
+	case parts[0] == "roles" && len(parts) == 2:
+		return "ROLE " + quoteName(parts[1]), true
+	case parts[0] == "functions" && len(parts) == 1:
+		return "ALL FUNCTIONS", true
+	case parts[0] == "functions" && len(parts) == 2:
+		return "ALL FUNCTIONS IN KEYSPACE " + quoteName(parts[1]), true
+	case parts[0] == "mbean" && len(parts) == 1:
+		return "ALL MBEANS", true
+	case parts[0] == "mbean" && len(parts) == 2:
+		return "MBEAN " + literal(parts[1]), true
+	}
+	return "", false
+}
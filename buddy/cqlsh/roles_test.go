@@ -0,0 +1,43 @@
+package cqlsh
+
+import "testing"
+
+var testRoles = []*Role{
+	{Name: "admin", Superuser: true},
+	{Name: "app", Login: true, SaltedHash: "$2a$10$abc", MemberOf: []string{"readers"}, Permissions: []*Permission{
+		{Resource: "data/app", Permissions: []string{"MODIFY", "SELECT"}},
+		{Resource: "functions/app/f[org.apache.cassandra.db.marshal.Int32Type]", Permissions: []string{"EXECUTE"}},
+	}},
+	{Name: "readers", Permissions: []*Permission{{Resource: "data/app/events", Permissions: []string{"SELECT"}}}},
+}
+
+func TestSelectRoles(t *testing.T) {
+	if roles := SelectRoles(testRoles, nil); len(roles) != 3 {
+		t.Fatalf("expected all roles, got %d", len(roles))
+	}
+	roles := SelectRoles(testRoles, []string{"app"})
+	if len(roles) != 2 || roles[0].Name != "app" || roles[1].Name != "readers" {
+		t.Fatalf("expected app and its parent role, got %+v", roles)
+	}
+}
+
+func TestRoleStatements(t *testing.T) {
+	stmts := RoleStatements(SelectRoles(testRoles, []string{"app"}))
+	want := []string{
+		"CREATE ROLE IF NOT EXISTS app WITH LOGIN = true AND SUPERUSER = false",
+		"UPDATE system_auth.roles SET salted_hash = '$2a$10$abc' WHERE role = 'app'",
+		"CREATE ROLE IF NOT EXISTS readers WITH LOGIN = false AND SUPERUSER = false",
+		"GRANT readers TO app",
+		"GRANT MODIFY ON KEYSPACE app TO app",
+		"GRANT SELECT ON KEYSPACE app TO app",
+		"GRANT SELECT ON TABLE app.events TO readers",
+	}
+	if len(stmts) != len(want) {
+		t.Fatalf("expected %d statements, got %q", len(want), stmts)
+	}
+	for i := range want {
+		if stmts[i] != want[i] {
+			t.Fatalf("statement %d = %q, want %q", i, stmts[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package cqlsh
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var createRegex = regexp.MustCompile(`(?is)^CREATE\s+(OR\s+REPLACE\s+)?(KEYSPACE|TABLE|TYPE|CUSTOM\s+INDEX|INDEX|MATERIALIZED\s+VIEW|FUNCTION|AGGREGATE|TRIGGER)\s+(IF\s+NOT\s+EXISTS\s+)?`)
+var nameRegex = regexp.MustCompile(`^("(?:[^"]|"")+"|[A-Za-z0-9_]+)(?:\.("(?:[^"]|"")+"|[A-Za-z0-9_]+))?`)
+var indexTargetRegex = regexp.MustCompile(`(?is)\bON\s+("(?:[^"]|"")+"|[A-Za-z0-9_]+)\.`)
+var replicationRegex = regexp.MustCompile(`(?is)replication\s*=\s*\{[^}]*\}`)
+
+// SplitStatements splits a CQL script into statements, semicolons inside
+// strings, quoted names, $$ function bodies and comments do not end a statement.
+// Comments are dropped.
+func SplitStatements(cql string) []string {
+	stmts := make([]string, 0)
+	var cur strings.Builder
+	flush := func() {
+		if stmt := strings.TrimSpace(cur.String()); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		cur.Reset()
+	}
+	for i := 0; i < len(cql); i++ {
+		c := cql[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := i + 1
+			for ; end < len(cql); end++ {
+				if cql[end] != c {
+					continue
+				}
+				// doubled quotes are escapes
+				if end+1 < len(cql) && cql[end+1] == c {
+					end++
+					continue
+				}
+				break
+			}
+			if end >= len(cql) {
+				cur.WriteString(cql[i:])
+				i = len(cql)
+				break
+			}
+			cur.WriteString(cql[i : end+1])
+			i = end
+		case strings.HasPrefix(cql[i:], "$$"):
+			end := strings.Index(cql[i+2:], "$$")
+			if end < 0 {
+				cur.WriteString(cql[i:])
+				i = len(cql)
+				break
+			}
+			cur.WriteString(cql[i : i+end+4])
+			i += end + 3
+		case strings.HasPrefix(cql[i:], "--") || strings.HasPrefix(cql[i:], "//"):
+			end := strings.IndexByte(cql[i:], '\n')
+			if end < 0 {
+				i = len(cql)
+				break
+			}
+			i += end
+			cur.WriteByte('\n')
+		case strings.HasPrefix(cql[i:], "/*"):
+			end := strings.Index(cql[i+2:], "*/")
+			if end < 0 {
+				i = len(cql)
+				break
+			}
+			i += end + 3
+		case c == ';':
+			flush()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
+
+// Statement is a CREATE statement from a schema script.
+type Statement struct {
+	CQL      string
+	Kind     string
+	Keyspace string
+	Name     string
+}
+
+// ParseStatement returns the kind and target of a CREATE statement, ok is false
+// for anything else.
+func ParseStatement(stmt string) (s Statement, ok bool) {
+	loc := createRegex.FindStringSubmatchIndex(stmt)
+	if loc == nil {
+		return s, false
+	}
+	s.CQL = stmt
+	s.Kind = strings.ToUpper(strings.Join(strings.Fields(stmt[loc[4]:loc[5]]), " "))
+	rest := stmt[loc[1]:]
+	name := nameRegex.FindStringSubmatch(rest)
+	if s.Kind == "KEYSPACE" {
+		if name != nil {
+			s.Keyspace = unquote(name[1])
+		}
+		return s, true
+	}
+	if strings.HasSuffix(s.Kind, "INDEX") {
+		if name != nil && !strings.EqualFold(name[1], "ON") {
+			s.Name = unquote(name[1])
+		}
+		if target := indexTargetRegex.FindStringSubmatch(rest); target != nil {
+			s.Keyspace = unquote(target[1])
+		}
+		return s, true
+	}
+	if name != nil {
+		if name[2] != "" {
+			s.Keyspace = unquote(name[1])
+			s.Name = unquote(name[2])
+		} else {
+			s.Name = unquote(name[1])
+		}
+	}
+	return s, true
+}
+
+// IsSystemKeyspace reports whether keyspace is managed by cassandra itself.
+func IsSystemKeyspace(keyspace string) bool {
+	return keyspace == "system" || strings.HasPrefix(keyspace, "system_") || keyspace == "dse_system"
+}
+
+// PrepareSchema turns a schema script into statements that only create what is
+// missing, system keyspaces are skipped. When replication is set keyspaces are
+// created with NetworkTopologyStrategy and the given replication factor per DC.
+func PrepareSchema(schema string, replication map[string]int) []string {
+	stmts := make([]string, 0)
+	for _, stmt := range SplitStatements(schema) {
+		s, ok := ParseStatement(stmt)
+		if !ok || IsSystemKeyspace(s.Keyspace) {
+			continue
+		}
+		cql := ifNotExists(s.CQL)
+		if s.Kind == "KEYSPACE" && len(replication) > 0 {
+			cql = replicationRegex.ReplaceAllLiteralString(cql, "replication = "+ReplicationMap(replication))
+		}
+		stmts = append(stmts, cql)
+	}
+	return stmts
+}
+
+// ReplicationMap renders a NetworkTopologyStrategy replication map.
+func ReplicationMap(replication map[string]int) string {
+	dcs := make([]string, 0, len(replication))
+	for dc := range replication {
+		dcs = append(dcs, dc)
+	}
+	sort.Strings(dcs)
+	parts := []string{"'class': 'NetworkTopologyStrategy'"}
+	for _, dc := range dcs {
+		parts = append(parts, fmt.Sprintf("'%s': '%d'", dc, replication[dc]))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// ifNotExists adds IF NOT EXISTS to a CREATE statement, CREATE OR REPLACE is
+// left alone as the two can not be combined.
+func ifNotExists(stmt string) string {
+	parts := createRegex.FindStringSubmatchIndex(stmt)
+	if parts == nil || parts[2] >= 0 || parts[6] >= 0 {
+		return stmt
+	}
+	return stmt[:parts[1]] + "IF NOT EXISTS " + stmt[parts[1]:]
+}
+
+func unquote(name string) string {
+	if len(name) > 1 && name[0] == '"' {
+		return strings.Replace(name[1:len(name)-1], `""`, `"`, -1)
+	}
+	return strings.ToLower(name)
+}
@@ -0,0 +1,82 @@
+package cqlsh
+
+import "testing"
+
+var testSchema = `
+CREATE KEYSPACE system_auth WITH replication = {'class': 'SimpleStrategy', 'replication_factor': '1'}  AND durable_writes = true;
+
+CREATE KEYSPACE app WITH replication = {'class': 'SimpleStrategy', 'replication_factor': '1'}  AND durable_writes = true;
+
+CREATE TYPE app.address (
+    street text,
+    city text
+);
+
+-- users; with a comment
+CREATE TABLE app.users (
+    id uuid PRIMARY KEY,
+    address frozen<address>
+) WITH comment = 'users; and addresses'
+    AND gc_grace_seconds = 864000;
+
+CREATE INDEX users_address_idx ON app.users (address);
+
+CREATE FUNCTION app.greet(name text)
+    CALLED ON NULL INPUT
+    RETURNS text
+    LANGUAGE java
+    AS $$return "hello; " + name;$$;
+
+CREATE TABLE system_auth.roles (
+    role text PRIMARY KEY
+);
+`
+
+func TestSplitStatements(t *testing.T) {
+	stmts := SplitStatements(testSchema)
+	if len(stmts) != 7 {
+		t.Fatalf("expected 7 statements, got %d: %q", len(stmts), stmts)
+	}
+	if stmts[3][len(stmts[3])-len("864000"):] != "864000" {
+		t.Fatalf("semicolon in string split statement: %q", stmts[3])
+	}
+}
+
+func TestParseStatement(t *testing.T) {
+	tests := []struct {
+		cql  string
+		want Statement
+	}{
+		{`CREATE KEYSPACE "App" WITH replication = {}`, Statement{Kind: "KEYSPACE", Keyspace: "App"}},
+		{`CREATE TABLE IF NOT EXISTS app.users (id uuid PRIMARY KEY)`, Statement{Kind: "TABLE", Keyspace: "app", Name: "users"}},
+		{`CREATE INDEX ON app.users (email)`, Statement{Kind: "INDEX", Keyspace: "app"}},
+		{`CREATE CUSTOM INDEX users_idx ON app.users (email) USING 'x'`, Statement{Kind: "CUSTOM INDEX", Keyspace: "app", Name: "users_idx"}},
+		{`CREATE MATERIALIZED VIEW app.users_by_email AS SELECT`, Statement{Kind: "MATERIALIZED VIEW", Keyspace: "app", Name: "users_by_email"}},
+	}
+	for _, test := range tests {
+		s, ok := ParseStatement(test.cql)
+		if !ok {
+			t.Fatalf("failed to parse %q", test.cql)
+		}
+		if s.Kind != test.want.Kind || s.Keyspace != test.want.Keyspace || s.Name != test.want.Name {
+			t.Fatalf("ParseStatement(%q) = %+v", test.cql, s)
+		}
+	}
+	if _, ok := ParseStatement("INSERT INTO app.users (id) VALUES (now())"); ok {
+		t.Fatal("parsed an insert as create statement")
+	}
+}
+
+func TestPrepareSchema(t *testing.T) {
+	stmts := PrepareSchema(testSchema, map[string]int{"us-west": 3, "eu-west": 2})
+	if len(stmts) != 5 {
+		t.Fatalf("expected 5 statements, got %d: %q", len(stmts), stmts)
+	}
+	want := "CREATE KEYSPACE IF NOT EXISTS app WITH replication = {'class': 'NetworkTopologyStrategy', 'eu-west': '2', 'us-west': '3'}  AND durable_writes = true"
+	if stmts[0] != want {
+		t.Fatalf("unexpected keyspace statement %q", stmts[0])
+	}
+	if stmts[2][:len("CREATE TABLE IF NOT EXISTS app.users")] != "CREATE TABLE IF NOT EXISTS app.users" {
+		t.Fatalf("unexpected table statement %q", stmts[2])
+	}
+}
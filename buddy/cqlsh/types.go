@@ -0,0 +1,264 @@
+package cqlsh
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+// native protocol type ids
+const (
+	TypeCustom    uint16 = 0x0000
+	TypeASCII     uint16 = 0x0001
+	TypeBigInt    uint16 = 0x0002
+	TypeBlob      uint16 = 0x0003
+	TypeBoolean   uint16 = 0x0004
+	TypeCounter   uint16 = 0x0005
+	TypeDecimal   uint16 = 0x0006
+	TypeDouble    uint16 = 0x0007
+	TypeFloat     uint16 = 0x0008
+	TypeInt       uint16 = 0x0009
+	TypeTimestamp uint16 = 0x000B
+	TypeUUID      uint16 = 0x000C
+	TypeVarchar   uint16 = 0x000D
+	TypeVarint    uint16 = 0x000E
+	TypeTimeUUID  uint16 = 0x000F
+	TypeInet      uint16 = 0x0010
+	TypeDate      uint16 = 0x0011
+	TypeTime      uint16 = 0x0012
+	TypeSmallInt  uint16 = 0x0013
+	TypeTinyInt   uint16 = 0x0014
+	TypeList      uint16 = 0x0020
+	TypeMap       uint16 = 0x0021
+	TypeSet       uint16 = 0x0022
+	TypeUDT       uint16 = 0x0030
+	TypeTuple     uint16 = 0x0031
+)
+
+// TypeInfo describes the type of a result column.
+type TypeInfo struct {
+	ID uint16
+	// Custom is the java class of a custom type.
+	Custom string
+	// Elems are the element types of collections and tuples, key and value for maps.
+	Elems []TypeInfo
+	// Keyspace, Name and Fields describe a user defined type.
+	Keyspace string
+	Name     string
+	Fields   []string
+}
+
+func (d *decoder) typeInfo() TypeInfo {
+	t := TypeInfo{ID: d.short()}
+	switch t.ID {
+	case TypeCustom:
+		t.Custom = d.string()
+	case TypeList, TypeSet:
+		t.Elems = []TypeInfo{d.typeInfo()}
+	case TypeMap:
+		t.Elems = []TypeInfo{d.typeInfo(), d.typeInfo()}
+	case TypeUDT:
+		t.Keyspace = d.string()
+		t.Name = d.string()
+		n := int(d.short())
+		for i := 0; i < n && d.err == nil; i++ {
+			t.Fields = append(t.Fields, d.string())
+			t.Elems = append(t.Elems, d.typeInfo())
+		}
+	case TypeTuple:
+		n := int(d.short())
+		for i := 0; i < n && d.err == nil; i++ {
+			t.Elems = append(t.Elems, d.typeInfo())
+		}
+	}
+	return t
+}
+
+// Decode converts a serialized value into a go value. Text types decode to
+// string, integers to the matching sized int, uuids to their string form,
+// lists, sets and tuples to []interface{}, maps to map[string]interface{} keyed
+// by the formatted key and user defined types to map[string]interface{}.
+func (t TypeInfo) Decode(b []byte) (interface{}, error) {
+	if b == nil {
+		return nil, nil
+	}
+	switch t.ID {
+	case TypeASCII, TypeVarchar:
+		return string(b), nil
+	case TypeBigInt, TypeCounter, TypeTime:
+		if len(b) != 8 {
+			return nil, t.sizeError(b)
+		}
+		return int64(binary.BigEndian.Uint64(b)), nil
+	case TypeBlob, TypeCustom:
+		return b, nil
+	case TypeBoolean:
+		if len(b) != 1 {
+			return nil, t.sizeError(b)
+		}
+		return b[0] != 0, nil
+	case TypeDecimal:
+		if len(b) < 4 {
+			return nil, t.sizeError(b)
+		}
+		scale := int32(binary.BigEndian.Uint32(b))
+		return formatDecimal(decodeVarint(b[4:]), scale), nil
+	case TypeDouble:
+		if len(b) != 8 {
+			return nil, t.sizeError(b)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case TypeFloat:
+		if len(b) != 4 {
+			return nil, t.sizeError(b)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), nil
+	case TypeInt:
+		if len(b) != 4 {
+			return nil, t.sizeError(b)
+		}
+		return int32(binary.BigEndian.Uint32(b)), nil
+	case TypeSmallInt:
+		if len(b) != 2 {
+			return nil, t.sizeError(b)
+		}
+		return int16(binary.BigEndian.Uint16(b)), nil
+	case TypeTinyInt:
+		if len(b) != 1 {
+			return nil, t.sizeError(b)
+		}
+		return int8(b[0]), nil
+	case TypeTimestamp:
+		if len(b) != 8 {
+			return nil, t.sizeError(b)
+		}
+		ms := int64(binary.BigEndian.Uint64(b))
+		return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond)).UTC(), nil
+	case TypeDate:
+		if len(b) != 4 {
+			return nil, t.sizeError(b)
+		}
+		// days since epoch centered at 2^31
+		days := int64(binary.BigEndian.Uint32(b)) - (1 << 31)
+		return time.Unix(days*24*60*60, 0).UTC(), nil
+	case TypeUUID, TypeTimeUUID:
+		if len(b) != 16 {
+			return nil, t.sizeError(b)
+		}
+		return formatUUID(b), nil
+	case TypeVarint:
+		return decodeVarint(b), nil
+	case TypeInet:
+		if len(b) != 4 && len(b) != 16 {
+			return nil, t.sizeError(b)
+		}
+		return net.IP(b), nil
+	case TypeList, TypeSet:
+		return t.decodeList(b)
+	case TypeMap:
+		return t.decodeMap(b)
+	case TypeUDT:
+		return t.decodeUDT(b)
+	case TypeTuple:
+		return t.decodeTuple(b)
+	}
+	return nil, fmt.Errorf("cql: unsupported type 0x%04x", t.ID)
+}
+
+func (t TypeInfo) decodeList(b []byte) (interface{}, error) {
+	d := &decoder{buf: b}
+	n := int(d.int())
+	list := make([]interface{}, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		v, err := t.Elems[0].Decode(d.bytes())
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, d.err
+}
+
+func (t TypeInfo) decodeMap(b []byte) (interface{}, error) {
+	d := &decoder{buf: b}
+	n := int(d.int())
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		k, err := t.Elems[0].Decode(d.bytes())
+		if err != nil {
+			return nil, err
+		}
+		v, err := t.Elems[1].Decode(d.bytes())
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(k)] = v
+	}
+	return m, d.err
+}
+
+func (t TypeInfo) decodeUDT(b []byte) (interface{}, error) {
+	d := &decoder{buf: b}
+	m := make(map[string]interface{}, len(t.Fields))
+	// serialized udts may have less fields than the type when it was altered
+	for i := 0; i < len(t.Fields) && len(d.buf) > 0; i++ {
+		v, err := t.Elems[i].Decode(d.bytes())
+		if err != nil {
+			return nil, err
+		}
+		m[t.Fields[i]] = v
+	}
+	return m, d.err
+}
+
+func (t TypeInfo) decodeTuple(b []byte) (interface{}, error) {
+	d := &decoder{buf: b}
+	tuple := make([]interface{}, 0, len(t.Elems))
+	for i := 0; i < len(t.Elems) && d.err == nil; i++ {
+		v, err := t.Elems[i].Decode(d.bytes())
+		if err != nil {
+			return nil, err
+		}
+		tuple = append(tuple, v)
+	}
+	return tuple, d.err
+}
+
+func (t TypeInfo) sizeError(b []byte) error {
+	return fmt.Errorf("cql: invalid %d byte value for type 0x%04x", len(b), t.ID)
+}
+
+func decodeVarint(b []byte) *big.Int {
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		// two's complement
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	return n
+}
+
+func formatDecimal(unscaled *big.Int, scale int32) string {
+	if scale <= 0 {
+		return new(big.Int).Mul(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-scale)), nil)).String()
+	}
+	digits := new(big.Int).Abs(unscaled).String()
+	sign := ""
+	if unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if len(digits) <= int(scale) {
+		digits = strings.Repeat("0", int(scale)-len(digits)+1) + digits
+	}
+	point := len(digits) - int(scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+func formatUUID(b []byte) string {
+	s := hex.EncodeToString(b)
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
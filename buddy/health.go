@@ -0,0 +1,182 @@
+package buddy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Nomon/cassandra-buddy/buddy/nodetool"
+	"github.com/Nomon/cassandra-buddy/buddy/structs"
+)
+
+// compactionPollInterval is how often postponed backups check compactions.
+var compactionPollInterval = 30 * time.Second
+
+// waitForCompactions postpones until no more than CompactionPendingLimit
+// compactions are pending, a snapshot taken during heavy compaction keeps the
+// compacted sstables on disk next to their replacements.
+func (srv *Server) waitForCompactions(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		stats, err := srv.nodetool().Compactionstats()
+		if err != nil {
+			return err
+		}
+		if stats.PendingTasks <= int64(srv.cfg.CompactionPendingLimit) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Backup postponed for %s, %d compactions still pending", timeout, stats.PendingTasks)
+		}
+		srv.log.Info("Postponing backup during compaction", "pending", stats.PendingTasks, "limit", srv.cfg.CompactionPendingLimit)
+		time.Sleep(compactionPollInterval)
+	}
+}
+
+// healthCacheTTL is how long collected statistics answer repeated health
+// checks, tablestats reads every table of the node.
+var healthCacheTTL = 10 * time.Second
+
+// healthReadTimeout bounds each statistics read, nodetool runs a jvm per read
+// and does not time out on its own.
+var healthReadTimeout = 30 * time.Second
+
+// nodeStats are the statistics health collects, errors are the problems
+// reading them.
+type nodeStats struct {
+	collected   time.Time
+	netstats    *nodetool.Netstats
+	tpstats     *nodetool.Tpstats
+	compactions *nodetool.CompactionStats
+	tables      *nodetool.Tablestats
+	errors      []string
+}
+
+// healthCache keeps the last nodeStats. Concurrent health checks wait for the
+// collection in progress instead of starting their own.
+type healthCache struct {
+	sync.Mutex
+	stats *nodeStats
+	// collecting is closed when the collection in progress is done
+	collecting chan struct{}
+}
+
+// nodeStats returns statistics collected within healthCacheTTL, a failed
+// read is recorded and the rest are still collected.
+func (srv *Server) nodeStats() *nodeStats {
+	c := &srv.healthCache
+	c.Lock()
+	if s := c.stats; s != nil && time.Since(s.collected) < healthCacheTTL {
+		c.Unlock()
+		return s
+	}
+	if done := c.collecting; done != nil {
+		c.Unlock()
+		<-done
+		c.Lock()
+		defer c.Unlock()
+		return c.stats
+	}
+	done := make(chan struct{})
+	c.collecting = done
+	c.Unlock()
+
+	s := srv.collectStats()
+	c.Lock()
+	c.stats = s
+	c.collecting = nil
+	c.Unlock()
+	close(done)
+	return s
+}
+
+func (srv *Server) collectStats() *nodeStats {
+	nt := srv.nodetool()
+	s := &nodeStats{collected: time.Now(), errors: make([]string, 0)}
+	if v, ok := s.read("netstats", func() (interface{}, error) { return nt.Netstats() }); ok {
+		s.netstats = v.(*nodetool.Netstats)
+	}
+	if v, ok := s.read("tpstats", func() (interface{}, error) { return nt.Tpstats() }); ok {
+		s.tpstats = v.(*nodetool.Tpstats)
+	}
+	if v, ok := s.read("compactionstats", func() (interface{}, error) { return nt.Compactionstats() }); ok {
+		s.compactions = v.(*nodetool.CompactionStats)
+	}
+	if v, ok := s.read("tablestats", func() (interface{}, error) { return nt.Tablestats() }); ok {
+		s.tables = v.(*nodetool.Tablestats)
+	}
+	return s
+}
+
+// read runs read for at most healthReadTimeout, a failed or unfinished read is
+// recorded as a problem.
+func (s *nodeStats) read(name string, read func() (interface{}, error)) (interface{}, bool) {
+	var v interface{}
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		v, err = read()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			s.errors = append(s.errors, fmt.Sprintf("Reading %s failed: %v", name, err))
+			return nil, false
+		}
+		return v, true
+	case <-time.After(healthReadTimeout):
+		s.errors = append(s.errors, fmt.Sprintf("Reading %s timed out after %s", name, healthReadTimeout))
+		return nil, false
+	}
+}
+
+// health collects thread pool, compaction and table statistics of the node
+// and the problems found in them. Statistics that cannot be read are problems
+// too, an unreachable node is unhealthy rather than an error.
+func (srv *Server) health(reply *structs.CassandraHealthReply) {
+	if readiness, _, _ := srv.node(); readiness != nil {
+		reply.Readiness = readiness.Phase()
+	}
+	stats := srv.nodeStats()
+	if stats.netstats != nil {
+		reply.Mode = stats.netstats.Mode
+	}
+	if stats.tpstats != nil {
+		reply.ThreadPools = stats.tpstats.Pools
+		reply.Dropped = stats.tpstats.Dropped
+	}
+	reply.Compactions = stats.compactions
+	reply.Problems = append(append([]string{}, stats.errors...), srv.healthProblems(reply.Readiness, stats.tpstats, stats.compactions, stats.tables)...)
+	reply.Healthy = len(reply.Problems) == 0
+}
+
+// healthProblems lists what needs attention. Dropped messages are counted since
+// the node started so they are reported but not a problem on their own.
+func (srv *Server) healthProblems(readiness string, tpstats *nodetool.Tpstats, compactions *nodetool.CompactionStats, tables *nodetool.Tablestats) []string {
+	problems := make([]string, 0)
+	if readiness != phaseReady {
+		problems = append(problems, fmt.Sprintf("Cassandra is %s", readiness))
+	}
+	if tpstats != nil {
+		for _, pool := range tpstats.Pools {
+			if pool.Blocked > 0 {
+				problems = append(problems, fmt.Sprintf("%s has %d blocked tasks", pool.Name, pool.Blocked))
+			}
+		}
+	}
+	if compactions != nil && compactions.PendingTasks > int64(srv.cfg.CompactionPendingLimit) {
+		problems = append(problems, fmt.Sprintf("%d compactions pending", compactions.PendingTasks))
+	}
+	if tables == nil {
+		return problems
+	}
+	for _, ks := range tables.Keyspaces {
+		for _, table := range ks.Tables {
+			if table.MaxPartitionBytes > srv.cfg.LargePartitionBytes {
+				problems = append(problems, fmt.Sprintf("%s.%s has a %d byte partition", ks.Name, table.Name, table.MaxPartitionBytes))
+			}
+		}
+	}
+	return problems
+}
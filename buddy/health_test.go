@@ -0,0 +1,176 @@
+package buddy
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Nomon/cassandra-buddy/buddy/nodetool"
+	"github.com/Nomon/cassandra-buddy/buddy/structs"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// statsNodetool answers with canned node statistics.
+type statsNodetool struct {
+	nodetool.Nodetool
+	compactions []*nodetool.CompactionStats
+	tpstats     *nodetool.Tpstats
+	tables      *nodetool.Tablestats
+	tablesErr   error
+}
+
+func (f *statsNodetool) Compactionstats() (*nodetool.CompactionStats, error) {
+	stats := f.compactions[0]
+	if len(f.compactions) > 1 {
+		f.compactions = f.compactions[1:]
+	}
+	return stats, nil
+}
+
+func (f *statsNodetool) Tpstats() (*nodetool.Tpstats, error) {
+	return f.tpstats, nil
+}
+
+func (f *statsNodetool) Tablestats() (*nodetool.Tablestats, error) {
+	return f.tables, f.tablesErr
+}
+
+func (f *statsNodetool) Netstats() (*nodetool.Netstats, error) {
+	return &nodetool.Netstats{Mode: "NORMAL"}, nil
+}
+
+// hangingNodetool never finishes reading tablestats until released.
+type hangingNodetool struct {
+	*statsNodetool
+	mu      sync.Mutex
+	reads   int
+	release chan struct{}
+}
+
+func (f *hangingNodetool) Tablestats() (*nodetool.Tablestats, error) {
+	f.mu.Lock()
+	f.reads++
+	f.mu.Unlock()
+	<-f.release
+	return f.tables, nil
+}
+
+func TestWaitForCompactions(t *testing.T) {
+	defer func(interval time.Duration) { compactionPollInterval = interval }(compactionPollInterval)
+	compactionPollInterval = time.Millisecond
+	nt := &statsNodetool{compactions: []*nodetool.CompactionStats{{PendingTasks: 150}, {PendingTasks: 120}, {PendingTasks: 80}}}
+	srv := &Server{cfg: NewConfig(), nt: nt, log: log15.New()}
+	if err := srv.waitForCompactions(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	nt.compactions = []*nodetool.CompactionStats{{PendingTasks: 150}}
+	err := srv.waitForCompactions(10 * time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "150 compactions still pending") {
+		t.Fatalf("expected the backup to be postponed, got %v", err)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	defer func(ttl time.Duration) { healthCacheTTL = ttl }(healthCacheTTL)
+	healthCacheTTL = 0
+	nt := &statsNodetool{
+		compactions: []*nodetool.CompactionStats{{PendingTasks: 3}},
+		tpstats: &nodetool.Tpstats{
+			Pools: []*nodetool.ThreadPool{
+				{Name: "MutationStage", Completed: 100},
+				{Name: "MemtableFlushWriter", Pending: 4, Blocked: 2},
+			},
+			Dropped: map[string]int64{"MUTATION": 12},
+		},
+		tables: &nodetool.Tablestats{Keyspaces: []*nodetool.KeyspaceStats{{
+			Name: "app",
+			Tables: []*nodetool.TableStats{
+				{Name: "events", MaxPartitionBytes: 8409007},
+				{Name: "timeline", MaxPartitionBytes: 268435456},
+			},
+		}}},
+	}
+	srv := &Server{cfg: NewConfig(), nt: nt, log: log15.New()}
+	srv.readiness = newReadinessChecker(nt, nil, true, srv.log)
+	srv.readiness.phase = phaseReady
+
+	var reply structs.CassandraHealthReply
+	srv.health(&reply)
+	want := []string{
+		"MemtableFlushWriter has 2 blocked tasks",
+		"app.timeline has a 268435456 byte partition",
+	}
+	if reply.Healthy || !reflect.DeepEqual(reply.Problems, want) {
+		t.Fatalf("unexpected problems %q", reply.Problems)
+	}
+	if reply.Mode != "NORMAL" || reply.Dropped["MUTATION"] != 12 || len(reply.ThreadPools) != 2 {
+		t.Fatalf("unexpected health %+v", reply)
+	}
+
+	nt.tpstats.Pools[1].Blocked = 0
+	nt.tables.Keyspaces[0].Tables = nt.tables.Keyspaces[0].Tables[:1]
+	reply = structs.CassandraHealthReply{}
+	srv.health(&reply)
+	if !reply.Healthy {
+		t.Fatalf("unexpected problems %q", reply.Problems)
+	}
+
+	nt.tablesErr = errors.New("connection refused")
+	reply = structs.CassandraHealthReply{}
+	srv.health(&reply)
+	if reply.Healthy || !reflect.DeepEqual(reply.Problems, []string{"Reading tablestats failed: connection refused"}) || reply.Mode != "NORMAL" {
+		t.Fatalf("unexpected health %+v", reply)
+	}
+
+	// statistics are collected once per healthCacheTTL
+	healthCacheTTL = time.Minute
+	nt.tablesErr = nil
+	srv.healthCache.stats = nil
+	srv.health(&structs.CassandraHealthReply{})
+	nt.tablesErr = errors.New("connection refused")
+	reply = structs.CassandraHealthReply{}
+	srv.health(&reply)
+	if !reply.Healthy {
+		t.Fatalf("statistics were not cached: %q", reply.Problems)
+	}
+}
+
+func TestHealthReadTimeout(t *testing.T) {
+	defer func(ttl, timeout time.Duration) { healthCacheTTL, healthReadTimeout = ttl, timeout }(healthCacheTTL, healthReadTimeout)
+	healthCacheTTL, healthReadTimeout = time.Minute, 20*time.Millisecond
+	nt := &hangingNodetool{
+		statsNodetool: &statsNodetool{
+			compactions: []*nodetool.CompactionStats{{}},
+			tpstats:     &nodetool.Tpstats{},
+			tables:      &nodetool.Tablestats{},
+		},
+		release: make(chan struct{}),
+	}
+	defer close(nt.release)
+	srv := &Server{cfg: NewConfig(), nt: nt, log: log15.New()}
+
+	// concurrent checks share one collection
+	var wg sync.WaitGroup
+	replies := make([]structs.CassandraHealthReply, 3)
+	for i := range replies {
+		wg.Add(1)
+		go func(reply *structs.CassandraHealthReply) {
+			defer wg.Done()
+			srv.health(reply)
+		}(&replies[i])
+	}
+	wg.Wait()
+	for _, reply := range replies {
+		if reply.Healthy || !strings.Contains(strings.Join(reply.Problems, "\n"), "Reading tablestats timed out after 20ms") {
+			t.Fatalf("unexpected problems %q", reply.Problems)
+		}
+	}
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	if nt.reads != 1 {
+		t.Fatalf("tablestats read %d times", nt.reads)
+	}
+}
@@ -1,8 +1,13 @@
 package buddy
 
 import (
+	"net/http"
+	"strconv"
+	"strings"
+
 	"github.com/Nomon/cassandra-buddy/buddy/structs"
 	"github.com/labstack/echo"
+	"github.com/labstack/echo/engine/standard"
 )
 
 func (srv *Server) CreateSnapshot(c echo.Context) error {
@@ -17,6 +22,27 @@ func (srv *Server) CreateSnapshot(c echo.Context) error {
 	return c.JSON(200, reply)
 }
 
+func (srv *Server) LocalSnapshots(c echo.Context) error {
+	var args structs.SnapshotsLocalRequest
+	var reply structs.SnapshotsLocalReply
+	if err := srv.RPC("Snapshots.Local", &args, &reply); err != nil {
+		return err
+	}
+	return c.JSON(200, reply)
+}
+
+func (srv *Server) PurgeLocalSnapshots(c echo.Context) error {
+	var args structs.SnapshotsPurgeRequest
+	var reply structs.SnapshotsPurgeReply
+	if err := c.Bind(&args); err != nil {
+		return err
+	}
+	if err := srv.RPC("Snapshots.Purge", &args, &reply); err != nil {
+		return err
+	}
+	return c.JSON(200, reply)
+}
+
 func (srv *Server) RestoreSnapshot(c echo.Context) error {
 	var args structs.SnapshotsRestoreRequest
 	var reply structs.SnapshotsRestoreReply
@@ -28,3 +54,173 @@ func (srv *Server) RestoreSnapshot(c echo.Context) error {
 	}
 	return c.JSON(200, reply)
 }
+
+func (srv *Server) SnapshotSchema(c echo.Context) error {
+	args := structs.SnapshotsSchemaRequest{Name: c.Param("name")}
+	var reply structs.SnapshotsSchemaReply
+	if err := srv.RPC("Snapshots.Schema", &args, &reply); err != nil {
+		return err
+	}
+	return c.String(200, reply.Schema)
+}
+
+func (srv *Server) SnapshotSchemaDiff(c echo.Context) error {
+	args := structs.SnapshotsSchemaRequest{Name: c.Param("name")}
+	var reply structs.SnapshotsSchemaDiffReply
+	if err := srv.RPC("Snapshots.SchemaDiff", &args, &reply); err != nil {
+		return err
+	}
+	return c.JSON(200, reply)
+}
+
+func (srv *Server) CassandraHealth(c echo.Context) error {
+	var args structs.CassandraHealthRequest
+	var reply structs.CassandraHealthReply
+	if err := srv.RPC("Cassandra.Health", &args, &reply); err != nil {
+		return err
+	}
+	return c.JSON(200, reply)
+}
+
+func (srv *Server) CassandraProcess(c echo.Context) error {
+	var args structs.CassandraProcessRequest
+	var reply structs.CassandraProcessReply
+	if err := srv.RPC("Cassandra.Process", &args, &reply); err != nil {
+		return err
+	}
+	return c.JSON(200, reply)
+}
+
+// CassandraLogs returns the last ?tail= lines of cassandra output as text, with
+// ?follow=true the response stays open and new lines are streamed as they are
+// written until the client goes away or the process is replaced.
+func (srv *Server) CassandraLogs(c echo.Context) error {
+	var args structs.CassandraLogsRequest
+	if tail := c.QueryParam("tail"); tail != "" {
+		n, err := strconv.Atoi(tail)
+		if err != nil {
+			return echo.NewHTTPError(400, "tail must be a number")
+		}
+		args.Tail = n
+	}
+	if c.QueryParam("follow") != "true" {
+		var reply structs.CassandraLogsReply
+		if err := srv.RPC("Cassandra.Logs", &args, &reply); err != nil {
+			return err
+		}
+		if len(reply.Lines) == 0 {
+			return c.String(200, "")
+		}
+		return c.String(200, strings.Join(reply.Lines, "\n")+"\n")
+	}
+
+	output := srv.process().Output()
+	if output == nil {
+		return echo.NewHTTPError(404, "Cassandra output is not captured for an attached cassandra")
+	}
+	// a nil channel never fires when the request has no context
+	var done <-chan struct{}
+	if req, ok := c.Request().(*standard.Request); ok {
+		done = req.Request.Context().Done()
+	}
+	lines, follow, cancel := output.Follow(args.Tail)
+	defer cancel()
+	res := c.Response()
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	res.WriteHeader(200)
+	flusher, _ := res.Writer().(http.Flusher)
+	write := func(line string) error {
+		if _, err := res.Write([]byte(line + "\n")); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+	for _, line := range lines {
+		if err := write(line); err != nil {
+			return nil
+		}
+	}
+	for {
+		select {
+		case <-done:
+			return nil
+		case line, ok := <-follow:
+			// closed when the process is replaced
+			if !ok {
+				return nil
+			}
+			if err := write(line); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+func (srv *Server) CassandraReplace(c echo.Context) error {
+	var args structs.CassandraReplaceRequest
+	var reply structs.CassandraReplaceReply
+	if err := c.Bind(&args); err != nil {
+		return err
+	}
+	if err := srv.RPC("Cassandra.Replace", &args, &reply); err != nil {
+		return err
+	}
+	return c.JSON(200, reply)
+}
+
+func (srv *Server) CassandraReplacement(c echo.Context) error {
+	var args structs.CassandraReplacementRequest
+	var reply structs.CassandraReplaceReply
+	if err := srv.RPC("Cassandra.Replacement", &args, &reply); err != nil {
+		return err
+	}
+	return c.JSON(200, reply)
+}
+
+func (srv *Server) CassandraStart(c echo.Context) error {
+	var args structs.CassandraStartRequest
+	var reply structs.CassandraStartReply
+	if err := c.Bind(&args); err != nil {
+		return err
+	}
+	if err := srv.RPC("Cassandra.Start", &args, &reply); err != nil {
+		return err
+	}
+	return c.JSON(200, reply)
+}
+
+func (srv *Server) CassandraStop(c echo.Context) error {
+	var args structs.CassandraStopRequest
+	var reply structs.CassandraStopReply
+	if err := c.Bind(&args); err != nil {
+		return err
+	}
+	if err := srv.RPC("Cassandra.Stop", &args, &reply); err != nil {
+		return err
+	}
+	return c.JSON(200, reply)
+}
+
+func (srv *Server) CassandraRestart(c echo.Context) error {
+	var args structs.CassandraRestartRequest
+	var reply structs.CassandraRestartReply
+	if err := c.Bind(&args); err != nil {
+		return err
+	}
+	if err := srv.RPC("Cassandra.Restart", &args, &reply); err != nil {
+		return err
+	}
+	return c.JSON(200, reply)
+}
+
+func (srv *Server) CassandraStatus(c echo.Context) error {
+	var args structs.CassandraStatusRequest
+	var reply structs.CassandraStatusReply
+	if err := srv.RPC("Cassandra.Status", &args, &reply); err != nil {
+		return err
+	}
+	return c.JSON(200, reply)
+}
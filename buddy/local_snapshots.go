@@ -0,0 +1,140 @@
+package buddy
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Nomon/cassandra-buddy/buddy/structs"
+)
+
+// snapshotCreated returns when snapshot name was taken, the newest modification
+// time of its table directories on any data disk.
+func snapshotCreated(dataPaths []string, name string) (time.Time, error) {
+	var created time.Time
+	for _, dataPath := range dataPaths {
+		dirs, err := filepath.Glob(filepath.Join(dataPath, "*", "*", "snapshots", name))
+		if err != nil {
+			return created, err
+		}
+		for _, dir := range dirs {
+			stat, err := os.Stat(dir)
+			if err != nil {
+				return created, err
+			}
+			if stat.ModTime().After(created) {
+				created = stat.ModTime()
+			}
+		}
+	}
+	return created, nil
+}
+
+// localSnapshots lists the snapshots on disk with the time they were taken,
+// newest first.
+func (srv *Server) localSnapshots() ([]*structs.LocalSnapshot, error) {
+	details, err := srv.nodetool().ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	snapshots := groupSnapshots(details)
+	for _, snapshot := range snapshots {
+		if snapshot.CreatedAt, err = snapshotCreated(srv.casConfig().DataPaths, snapshot.Name); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt) })
+	return snapshots, nil
+}
+
+// keptSnapshots returns the names of the newest SnapshotsKeep local snapshots
+// that are in the store.
+func (srv *Server) keptSnapshots(snapshots []*structs.LocalSnapshot) (map[string]bool, error) {
+	kept := make(map[string]bool)
+	for _, snapshot := range snapshots {
+		if len(kept) >= srv.cfg.SnapshotsKeep {
+			break
+		}
+		uploaded, err := srv.store.Uploaded(snapshot.Name)
+		if err != nil {
+			return nil, err
+		}
+		if uploaded {
+			kept[snapshot.Name] = true
+		}
+	}
+	return kept, nil
+}
+
+// clearUploadedSnapshots clears the local copy of snapshot name once it is in
+// the store, along with uploaded snapshots past SnapshotsKeep. Snapshots that
+// were never uploaded are left for purgeSnapshots.
+func (srv *Server) clearUploadedSnapshots(name string) error {
+	if srv.cfg.SnapshotsKeep <= 0 {
+		return srv.nodetool().ClearSnapshot(name, nil, nil)
+	}
+	snapshots, err := srv.localSnapshots()
+	if err != nil {
+		return err
+	}
+	kept, err := srv.keptSnapshots(snapshots)
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		if kept[snapshot.Name] {
+			continue
+		}
+		uploaded, err := srv.store.Uploaded(snapshot.Name)
+		if err != nil {
+			return err
+		}
+		if !uploaded {
+			continue
+		}
+		srv.log.Info("Clearing uploaded snapshot", "name", snapshot.Name, "size", snapshot.SizeOnDisk)
+		if err := srv.nodetool().ClearSnapshot(snapshot.Name, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeSnapshots clears the local snapshots taken before olderThan ago, except
+// the ones kept for fast restores and the ones being backed up.
+func (srv *Server) purgeSnapshots(olderThan time.Duration, dryRun bool) ([]*structs.LocalSnapshot, error) {
+	snapshots, err := srv.localSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	kept, err := srv.keptSnapshots(snapshots)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range srv.operations.list() {
+		if op.Kind == operationBackup {
+			kept[op.Name] = true
+		}
+	}
+	cutoff := time.Now().Add(-olderThan)
+	purged := make([]*structs.LocalSnapshot, 0)
+	for _, snapshot := range snapshots {
+		if kept[snapshot.Name] || snapshot.CreatedAt.After(cutoff) {
+			continue
+		}
+		// the age of a snapshot without a directory on the data paths is unknown
+		if snapshot.CreatedAt.IsZero() {
+			srv.log.Warn("Not purging snapshot of unknown age", "name", snapshot.Name)
+			continue
+		}
+		if !dryRun {
+			srv.log.Info("Purging snapshot", "name", snapshot.Name, "created", snapshot.CreatedAt, "size", snapshot.SizeOnDisk)
+			if err := srv.nodetool().ClearSnapshot(snapshot.Name, nil, nil); err != nil {
+				return purged, err
+			}
+		}
+		purged = append(purged, snapshot)
+	}
+	return purged, nil
+}
@@ -0,0 +1,126 @@
+package buddy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Nomon/cassandra-buddy/buddy/cassandra"
+	"github.com/Nomon/cassandra-buddy/buddy/nodetool"
+	"github.com/Nomon/cassandra-buddy/datastore"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// snapshotNodetool lists snapshots and records the cleared ones.
+type snapshotNodetool struct {
+	nodetool.Nodetool
+	details []*nodetool.SnapshotDetails
+	cleared []string
+}
+
+func (f *snapshotNodetool) ListSnapshots() ([]*nodetool.SnapshotDetails, error) {
+	return f.details, nil
+}
+
+func (f *snapshotNodetool) ClearSnapshot(name string, keyspaces, tables []string) error {
+	f.cleared = append(f.cleared, name)
+	return nil
+}
+
+type uploadedStore struct {
+	datastore.Store
+	uploaded map[string]bool
+}
+
+func (s *uploadedStore) Uploaded(name string) (bool, error) {
+	return s.uploaded[name], nil
+}
+
+// testSnapshotServer creates snapshots taken the given time ago on disk.
+func testSnapshotServer(t *testing.T, keep int, ages map[string]time.Duration, uploaded ...string) (*Server, *snapshotNodetool) {
+	dir, err := ioutil.TempDir("", "buddy-snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nt := &snapshotNodetool{}
+	for name, age := range ages {
+		snap := filepath.Join(dir, "app", "events-5b1a7e20f6a611e5a8a4c1d3a4c7e9a1", "snapshots", name)
+		if err := os.MkdirAll(snap, 0755); err != nil {
+			t.Fatal(err)
+		}
+		created := time.Now().Add(-age)
+		if err := os.Chtimes(snap, created, created); err != nil {
+			t.Fatal(err)
+		}
+		nt.details = append(nt.details, &nodetool.SnapshotDetails{Name: name, Keyspace: "app", Table: "events", SizeOnDisk: 1024})
+	}
+	store := &uploadedStore{uploaded: make(map[string]bool)}
+	for _, name := range uploaded {
+		store.uploaded[name] = true
+	}
+	cfg := NewConfig()
+	cfg.SnapshotsKeep = keep
+	cascfg := cassandra.DefaultConfig()
+	cascfg.DataPaths = []string{dir}
+	return &Server{cfg: cfg, cascfg: cascfg, nt: nt, store: store, log: log15.New()}, nt
+}
+
+func TestClearUploadedSnapshots(t *testing.T) {
+	srv, nt := testSnapshotServer(t, 0, map[string]time.Duration{"new": time.Hour}, "new")
+	defer os.RemoveAll(srv.cascfg.DataPaths[0])
+	if err := srv.clearUploadedSnapshots("new"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nt.cleared, []string{"new"}) {
+		t.Fatalf("cleared %v", nt.cleared)
+	}
+
+	srv, nt = testSnapshotServer(t, 2, map[string]time.Duration{
+		"new":     time.Hour,
+		"older":   2 * time.Hour,
+		"oldest":  3 * time.Hour,
+		"failed":  4 * time.Hour,
+		"ancient": 5 * time.Hour,
+	}, "new", "older", "oldest", "ancient")
+	defer os.RemoveAll(srv.cascfg.DataPaths[0])
+	if err := srv.clearUploadedSnapshots("new"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nt.cleared, []string{"oldest", "ancient"}) {
+		t.Fatalf("cleared %v", nt.cleared)
+	}
+}
+
+func TestPurgeSnapshots(t *testing.T) {
+	srv, nt := testSnapshotServer(t, 1, map[string]time.Duration{
+		"kept":    48 * time.Hour,
+		"running": 72 * time.Hour,
+		"orphan":  96 * time.Hour,
+		"recent":  time.Hour,
+	}, "kept")
+	defer os.RemoveAll(srv.cascfg.DataPaths[0])
+	// listed by cassandra without a directory on the data paths
+	nt.details = append(nt.details, &nodetool.SnapshotDetails{Name: "unknown", Keyspace: "app", Table: "events"})
+	op, err := srv.operations.begin(operationBackup, "running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.operations.end(op)
+
+	purged, err := srv.purgeSnapshots(24*time.Hour, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 1 || purged[0].Name != "orphan" || len(nt.cleared) != 0 {
+		t.Fatalf("dry run purged %v, cleared %v", purged, nt.cleared)
+	}
+	if _, err := srv.purgeSnapshots(24*time.Hour, false); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nt.cleared, []string{"orphan"}) {
+		t.Fatalf("cleared %v", nt.cleared)
+	}
+}
@@ -38,3 +38,14 @@ func NewClusterInfo(d []byte) (*ClusterInfo, error) {
 	}
 	return c, nil
 }
+
+// SchemaAgreement reports whether all reachable nodes have the same schema version.
+func (c *ClusterInfo) SchemaAgreement() bool {
+	versions := 0
+	for version := range c.SchemaVersions {
+		if version != "UNREACHABLE" {
+			versions++
+		}
+	}
+	return versions == 1
+}
@@ -10,6 +10,16 @@ var testInfo = []byte(`Cluster Information:
 		e094133b-e2f4-32f0-a359-5557f36a7259: [172.18.35.82, 172.18.36.63, 172.18.37.220]
 `)
 
+var testDisagreement = []byte(`Cluster Information:
+	Name: challenge-cassandra
+	Snitch: org.apache.cassandra.locator.DynamicEndpointSnitch
+	Partitioner: org.apache.cassandra.dht.Murmur3Partitioner
+	Schema versions:
+		e094133b-e2f4-32f0-a359-5557f36a7259: [172.18.35.82, 172.18.36.63]
+
+		86afa796-d883-3932-aa73-6b017cef0d19: [172.18.37.220]
+`)
+
 func TestNewClusterInfo(t *testing.T) {
 	info, err := NewClusterInfo(testInfo)
 	if err != nil {
@@ -31,3 +41,20 @@ func TestNewClusterInfo(t *testing.T) {
 		t.Fatal("Failed to parse schema versions")
 	}
 }
+
+func TestSchemaAgreement(t *testing.T) {
+	info, err := NewClusterInfo(testInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.SchemaAgreement() {
+		t.Fatal("expected schema agreement")
+	}
+	info, err = NewClusterInfo(testDisagreement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SchemaAgreement() {
+		t.Fatal("expected schema disagreement")
+	}
+}
@@ -0,0 +1,72 @@
+package nodetool
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CompactionStats are the compactions running and pending on a node.
+type CompactionStats struct {
+	PendingTasks int64
+	// Pending counts pending tasks by keyspace.table when nodetool breaks them
+	// down.
+	Pending     map[string]int64
+	Compactions []*Compaction
+	// RemainingTime is nodetool's estimate for the active compactions.
+	RemainingTime string
+}
+
+// Compaction is one running compaction, validation, cleanup or index build.
+type Compaction struct {
+	ID        string
+	Type      string
+	Keyspace  string
+	Table     string
+	Completed int64
+	Total     int64
+	Unit      string
+	Progress  float64
+}
+
+var compactionPendingRegex = regexp.MustCompile(`^pending tasks: (\d+)`)
+var compactionPendingTableRegex = regexp.MustCompile(`^- (\S+)\.(\S+): (\d+)$`)
+var compactionRegex = regexp.MustCompile(`^\s*(?:([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})\s+)?(\S+(?: \S+)*?)\s+(\S+)\s+(\S+)\s+(\d+)\s+(\d+)\s+(\S+)\s+([0-9.]+)%\s*$`)
+var compactionRemainingRegex = regexp.MustCompile(`^Active compaction remaining time\s*:\s*(\S+)`)
+
+func NewCompactionStats(d []byte) *CompactionStats {
+	c := &CompactionStats{Pending: make(map[string]int64), Compactions: make([]*Compaction, 0)}
+	for _, line := range strings.Split(string(d), "\n") {
+		if parts := compactionPendingRegex.FindStringSubmatch(line); parts != nil {
+			c.PendingTasks, _ = strconv.ParseInt(parts[1], 10, 64)
+		} else if parts := compactionPendingTableRegex.FindStringSubmatch(strings.TrimSpace(line)); parts != nil {
+			c.Pending[parts[1]+"."+parts[2]], _ = strconv.ParseInt(parts[3], 10, 64)
+		} else if parts := compactionRemainingRegex.FindStringSubmatch(line); parts != nil {
+			c.RemainingTime = parts[1]
+		} else if parts := compactionRegex.FindStringSubmatch(line); parts != nil {
+			compaction := &Compaction{
+				ID:       parts[1],
+				Type:     parts[2],
+				Keyspace: parts[3],
+				Table:    parts[4],
+				Unit:     parts[7],
+			}
+			compaction.Completed, _ = strconv.ParseInt(parts[5], 10, 64)
+			compaction.Total, _ = strconv.ParseInt(parts[6], 10, 64)
+			compaction.Progress, _ = strconv.ParseFloat(parts[8], 64)
+			c.Compactions = append(c.Compactions, compaction)
+		}
+	}
+	return c
+}
+
+// Remaining returns the bytes the running compactions still have to process.
+func (c *CompactionStats) Remaining() int64 {
+	var remaining int64
+	for _, compaction := range c.Compactions {
+		if compaction.Unit == "bytes" && compaction.Total > compaction.Completed {
+			remaining += compaction.Total - compaction.Completed
+		}
+	}
+	return remaining
+}
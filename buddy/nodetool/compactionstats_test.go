@@ -0,0 +1,53 @@
+package nodetool
+
+import "testing"
+
+var testCompactionStats = []byte(`pending tasks: 5
+- app.events: 3
+- system.size_estimates: 2
+
+id                                   compaction type             keyspace table  completed  total      unit  progress
+6a8c1b00-f6a6-11e5-a8a4-c1d3a4c7e9a1 Compaction                  app      events 268435456  1073741824 bytes 25.00%
+7b9d2c10-f6a6-11e5-a8a4-c1d3a4c7e9a1 Anticompaction after repair app      users  1024       4096       bytes 25.00%
+Active compaction remaining time :   0h01m32s
+`)
+
+var testCompactionStatsIdle = []byte(`pending tasks: 0
+`)
+
+func TestNewCompactionStats(t *testing.T) {
+	stats := NewCompactionStats(testCompactionStats)
+	if stats.PendingTasks != 5 || stats.Pending["app.events"] != 3 || stats.Pending["system.size_estimates"] != 2 {
+		t.Fatalf("unexpected pending tasks %d %v", stats.PendingTasks, stats.Pending)
+	}
+	if len(stats.Compactions) != 2 {
+		t.Fatalf("expected 2 compactions, got %d", len(stats.Compactions))
+	}
+	want := Compaction{
+		ID:        "6a8c1b00-f6a6-11e5-a8a4-c1d3a4c7e9a1",
+		Type:      "Compaction",
+		Keyspace:  "app",
+		Table:     "events",
+		Completed: 268435456,
+		Total:     1073741824,
+		Unit:      "bytes",
+		Progress:  25,
+	}
+	if *stats.Compactions[0] != want {
+		t.Fatalf("unexpected compaction %+v", stats.Compactions[0])
+	}
+	if c := stats.Compactions[1]; c.Type != "Anticompaction after repair" || c.Table != "users" {
+		t.Fatalf("unexpected compaction %+v", c)
+	}
+	if stats.RemainingTime != "0h01m32s" {
+		t.Fatalf("unexpected remaining time %q", stats.RemainingTime)
+	}
+	if remaining := stats.Remaining(); remaining != 805306368+3072 {
+		t.Fatalf("unexpected remaining bytes %d", remaining)
+	}
+
+	idle := NewCompactionStats(testCompactionStatsIdle)
+	if idle.PendingTasks != 0 || len(idle.Compactions) != 0 {
+		t.Fatalf("unexpected idle stats %+v", idle)
+	}
+}
@@ -0,0 +1,655 @@
+package nodetool
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MBeans used by the jolokia client
+const (
+	storageServiceMBean    = "org.apache.cassandra.db:type=StorageService"
+	storageProxyMBean      = "org.apache.cassandra.db:type=StorageProxy"
+	snitchInfoMBean        = "org.apache.cassandra.db:type=EndpointSnitchInfo"
+	streamManagerMBean     = "org.apache.cassandra.net:type=StreamManager"
+	compactionManagerMBean = "org.apache.cassandra.db:type=CompactionManager"
+	exceptionsMBean        = "org.apache.cassandra.metrics:type=Storage,name=Exceptions"
+	memoryMBean            = "java.lang:type=Memory"
+	runtimeMBean           = "java.lang:type=Runtime"
+)
+
+// jolokia implements Nodetool over the HTTP api of the jolokia agent loaded
+// into the cassandra jvm. Calls are plain HTTP requests instead of a nodetool
+// jvm each and results are decoded from json instead of parsed from text.
+type jolokia struct {
+	cfg    *Config
+	client *http.Client
+	// operations runs snapshots, refreshes and drains, which take as long as
+	// the node needs. Callers bound them, see stopCassandra.
+	operations *http.Client
+}
+
+func newJolokia(cfg *Config) *jolokia {
+	return &jolokia{
+		cfg:        cfg,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		operations: &http.Client{},
+	}
+}
+
+type jolokiaRequest struct {
+	Type      string        `json:"type"`
+	MBean     string        `json:"mbean"`
+	Attribute interface{}   `json:"attribute,omitempty"`
+	Operation string        `json:"operation,omitempty"`
+	Arguments []interface{} `json:"arguments,omitempty"`
+}
+
+type jolokiaResponse struct {
+	Value  json.RawMessage `json:"value"`
+	Status int             `json:"status"`
+	Error  string          `json:"error"`
+}
+
+func readRequest(mbean string, attribute interface{}) jolokiaRequest {
+	return jolokiaRequest{Type: "read", MBean: mbean, Attribute: attribute}
+}
+
+func execRequest(mbean, operation string, args ...interface{}) jolokiaRequest {
+	if args == nil {
+		args = []interface{}{}
+	}
+	return jolokiaRequest{Type: "exec", MBean: mbean, Operation: operation, Arguments: args}
+}
+
+// do sends the requests as one bulk request and returns the values in order.
+func (j *jolokia) do(reqs ...jolokiaRequest) ([]json.RawMessage, error) {
+	return j.send(j.client, reqs)
+}
+
+// exec is do for long running operations, it is not timed out.
+func (j *jolokia) exec(reqs ...jolokiaRequest) ([]json.RawMessage, error) {
+	return j.send(j.operations, reqs)
+}
+
+func (j *jolokia) send(client *http.Client, reqs []jolokiaRequest) ([]json.RawMessage, error) {
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", strings.TrimRight(j.cfg.JolokiaURL, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if j.cfg.JolokiaUsername != "" {
+		req.SetBasicAuth(j.cfg.JolokiaUsername, j.cfg.JolokiaPassword)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("Jolokia returned %s", res.Status)
+	}
+	var responses []jolokiaResponse
+	if err := json.NewDecoder(res.Body).Decode(&responses); err != nil {
+		return nil, err
+	}
+	if len(responses) != len(reqs) {
+		return nil, fmt.Errorf("Jolokia returned %d responses to %d requests", len(responses), len(reqs))
+	}
+	values := make([]json.RawMessage, len(responses))
+	for i, r := range responses {
+		if r.Status != 200 {
+			return nil, fmt.Errorf("Jolokia %s %s failed: %s", reqs[i].Type, reqs[i].MBean, r.Error)
+		}
+		values[i] = r.Value
+	}
+	return values, nil
+}
+
+// call sends the requests and decodes each value into the matching dst.
+func (j *jolokia) call(reqs []jolokiaRequest, dst ...interface{}) error {
+	values, err := j.do(reqs...)
+	if err != nil {
+		return err
+	}
+	for i, v := range dst {
+		if v == nil {
+			continue
+		}
+		if err := json.Unmarshal(values[i], v); err != nil {
+			return fmt.Errorf("Decoding jolokia %s %s: %v", reqs[i].Type, reqs[i].MBean, err)
+		}
+	}
+	return nil
+}
+
+func (j *jolokia) Info() (*Info, error) {
+	var ss struct {
+		LocalHostId             string
+		GossipRunning           bool
+		RPCServerRunning        bool
+		NativeTransportRunning  bool
+		LoadString              string
+		CurrentGenerationNumber int64
+	}
+	var snitch struct {
+		Datacenter string
+		Rack       string
+	}
+	var memory struct {
+		HeapMemoryUsage struct {
+			Used int64 `json:"used"`
+			Max  int64 `json:"max"`
+		}
+	}
+	var uptime int64
+	var exceptions int64
+	err := j.call([]jolokiaRequest{
+		readRequest(storageServiceMBean, []string{"LocalHostId", "GossipRunning", "RPCServerRunning",
+			"NativeTransportRunning", "LoadString", "CurrentGenerationNumber"}),
+		readRequest(snitchInfoMBean, []string{"Datacenter", "Rack"}),
+		readRequest(memoryMBean, []string{"HeapMemoryUsage"}),
+		readRequest(runtimeMBean, "Uptime"),
+		readRequest(exceptionsMBean, "Count"),
+	}, &ss, &snitch, &memory, &uptime, &exceptions)
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{
+		ID:                    ss.LocalHostId,
+		GossipActive:          ss.GossipRunning,
+		ThriftActive:          ss.RPCServerRunning,
+		NativeTransportActive: ss.NativeTransportRunning,
+		Load:                  ss.LoadString,
+		GenerationNo:          ss.CurrentGenerationNumber,
+		Uptime:                strconv.FormatInt(uptime/1000, 10),
+		HeapUsed:              float64(memory.HeapMemoryUsage.Used) / 1024 / 1024,
+		HeapMax:               float64(memory.HeapMemoryUsage.Max) / 1024 / 1024,
+		DataCenter:            snitch.Datacenter,
+		Rack:                  snitch.Rack,
+		Exceptions:            exceptions,
+	}
+	if info.HeapMax > 0 {
+		info.HeapUsage = (info.HeapUsed / info.HeapMax) * 100
+	}
+	return info, nil
+}
+
+func (j *jolokia) ClusterInfo() (*ClusterInfo, error) {
+	var ss struct {
+		ClusterName     string
+		PartitionerName string
+	}
+	var snitch struct {
+		SnitchName string
+	}
+	c := &ClusterInfo{}
+	err := j.call([]jolokiaRequest{
+		readRequest(storageServiceMBean, []string{"ClusterName", "PartitionerName"}),
+		readRequest(snitchInfoMBean, []string{"SnitchName"}),
+		readRequest(storageProxyMBean, "SchemaVersions"),
+	}, &ss, &snitch, &c.SchemaVersions)
+	if err != nil {
+		return nil, err
+	}
+	c.Name = ss.ClusterName
+	c.Partitioner = ss.PartitionerName
+	c.Snitch = snitch.SnitchName
+	return c, nil
+}
+
+func (j *jolokia) Status() (*Status, error) {
+	var ss struct {
+		LiveNodes          []string
+		UnreachableNodes   []string
+		JoiningNodes       []string
+		LeavingNodes       []string
+		MovingNodes        []string
+		LoadMap            map[string]string
+		HostIdMap          map[string]string
+		Ownership          map[string]float64
+		TokenToEndpointMap map[string]string
+	}
+	err := j.call([]jolokiaRequest{
+		readRequest(storageServiceMBean, []string{"LiveNodes", "UnreachableNodes", "JoiningNodes",
+			"LeavingNodes", "MovingNodes", "LoadMap", "HostIdMap", "Ownership", "TokenToEndpointMap"}),
+	}, &ss)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make(map[string]int)
+	for _, endpoint := range ss.TokenToEndpointMap {
+		tokens[endpoint]++
+	}
+	owns := make(map[string]float64)
+	for endpoint, o := range ss.Ownership {
+		// keys are InetAddress strings like hostname/10.0.0.1
+		owns[endpoint[strings.LastIndex(endpoint, "/")+1:]] = o
+	}
+	addresses := make([]string, 0, len(ss.HostIdMap))
+	for address := range ss.HostIdMap {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	if len(addresses) == 0 {
+		return &Status{Datacenters: []Datacenter{}}, nil
+	}
+
+	reqs := make([]jolokiaRequest, 0, 2*len(addresses))
+	datacenters := make([]string, len(addresses))
+	racks := make([]string, len(addresses))
+	dst := make([]interface{}, 0, 2*len(addresses))
+	for i, address := range addresses {
+		reqs = append(reqs, execRequest(snitchInfoMBean, "getDatacenter", address))
+		reqs = append(reqs, execRequest(snitchInfoMBean, "getRack", address))
+		dst = append(dst, &datacenters[i], &racks[i])
+	}
+	if err := j.call(reqs, dst...); err != nil {
+		return nil, err
+	}
+	dcs := make(map[string]*Datacenter)
+	names := make([]string, 0)
+	for i, address := range addresses {
+		dc, rack := datacenters[i], racks[i]
+		node := Node{
+			State:   nodeState(address, ss.LiveNodes, ss.JoiningNodes, ss.LeavingNodes, ss.MovingNodes),
+			Address: address,
+			Load:    ss.LoadMap[address],
+			Tokens:  strconv.Itoa(tokens[address]),
+			Owns:    "?",
+			HostID:  ss.HostIdMap[address],
+			Rack:    rack,
+		}
+		if o, ok := owns[address]; ok {
+			node.Owns = fmt.Sprintf("%.1f%%", o*100)
+		}
+		if _, ok := dcs[dc]; !ok {
+			dcs[dc] = &Datacenter{Name: dc, Nodes: make([]Node, 0)}
+			names = append(names, dc)
+		}
+		dcs[dc].Nodes = append(dcs[dc].Nodes, node)
+	}
+	sort.Strings(names)
+	status := &Status{Datacenters: make([]Datacenter, 0, len(names))}
+	for _, name := range names {
+		status.Datacenters = append(status.Datacenters, *dcs[name])
+	}
+	return status, nil
+}
+
+// nodeState returns the two letter state nodetool status prints, like UN or DJ.
+func nodeState(address string, live, joining, leaving, moving []string) string {
+	contains := func(list []string) bool {
+		for _, a := range list {
+			if a == address {
+				return true
+			}
+		}
+		return false
+	}
+	state := "D"
+	if contains(live) {
+		state = "U"
+	}
+	switch {
+	case contains(joining):
+		return state + "J"
+	case contains(leaving):
+		return state + "L"
+	case contains(moving):
+		return state + "M"
+	}
+	return state + "N"
+}
+
+func (j *jolokia) Snapshot(name string, keyspaces, tables []string) (*Snapshot, error) {
+	reqs := make([]jolokiaRequest, 0)
+	if len(tables) > 0 {
+		if len(keyspaces) != 1 {
+			return nil, errors.New("Snapshotting tables requires exactly one keyspace")
+		}
+		for _, table := range tables {
+			reqs = append(reqs, execRequest(storageServiceMBean, "takeColumnFamilySnapshot", keyspaces[0], table, name))
+		}
+	} else {
+		if keyspaces == nil {
+			keyspaces = []string{}
+		}
+		reqs = append(reqs, execRequest(storageServiceMBean, "takeSnapshot(java.lang.String,[Ljava.lang.String;)", name, keyspaces))
+	}
+	if _, err := j.exec(reqs...); err != nil {
+		return nil, err
+	}
+	return &Snapshot{Name: name, Path: name, Keyspaces: keyspaces}, nil
+}
+
+// ListSnapshots reads SnapshotDetails, jolokia renders the tabular data of each
+// snapshot as maps nested by its index columns so the rows are searched for.
+func (j *jolokia) ListSnapshots() ([]*SnapshotDetails, error) {
+	var snapshots map[string]interface{}
+	if err := j.call([]jolokiaRequest{readRequest(storageServiceMBean, "SnapshotDetails")}, &snapshots); err != nil {
+		return nil, err
+	}
+	details := make([]*SnapshotDetails, 0)
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		case map[string]interface{}:
+			if name, ok := v["Snapshot name"].(string); ok {
+				d := &SnapshotDetails{Name: name}
+				d.Keyspace, _ = v["Keyspace name"].(string)
+				d.Table, _ = v["Column family name"].(string)
+				if s, ok := v["True size"].(string); ok {
+					d.TrueSize, _ = ParseSize(s)
+				}
+				if s, ok := v["Size on disk"].(string); ok {
+					d.SizeOnDisk, _ = ParseSize(s)
+				}
+				details = append(details, d)
+				return
+			}
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(v[k])
+			}
+		}
+	}
+	walk(snapshots)
+	return details, nil
+}
+
+func (j *jolokia) ClearSnapshot(name string, keyspaces, tables []string) error {
+	if len(tables) > 0 {
+		return errors.New("Clearing snapshots of single tables is not supported")
+	}
+	if keyspaces == nil {
+		keyspaces = []string{}
+	}
+	_, err := j.exec(execRequest(storageServiceMBean, "clearSnapshot", name, keyspaces))
+	return err
+}
+
+func (j *jolokia) Refresh(keyspace, table string) error {
+	if keyspace == "" || table == "" {
+		return errors.New("Refresh requires keyspace and column family")
+	}
+	_, err := j.exec(execRequest(storageServiceMBean, "loadNewSSTables", keyspace, table))
+	return err
+}
+
+func (j *jolokia) Drain() error {
+	_, err := j.exec(execRequest(storageServiceMBean, "drain"))
+	return err
+}
+
+func (j *jolokia) Netstats() (*Netstats, error) {
+	type summary struct {
+		Files     int64 `json:"files"`
+		TotalSize int64 `json:"totalSize"`
+	}
+	type progress struct {
+		CurrentBytes int64 `json:"currentBytes"`
+		TotalBytes   int64 `json:"totalBytes"`
+	}
+	var mode string
+	var streams []struct {
+		PlanID      string `json:"planId"`
+		Description string `json:"description"`
+		Sessions    []struct {
+			Peer               string     `json:"peer"`
+			ReceivingSummaries []summary  `json:"receivingSummaries"`
+			SendingSummaries   []summary  `json:"sendingSummaries"`
+			ReceivingFiles     []progress `json:"receivingFiles"`
+			SendingFiles       []progress `json:"sendingFiles"`
+		} `json:"sessions"`
+	}
+	err := j.call([]jolokiaRequest{
+		readRequest(storageServiceMBean, "OperationMode"),
+		readRequest(streamManagerMBean, "CurrentStreams"),
+	}, &mode, &streams)
+	if err != nil {
+		return nil, err
+	}
+	n := &Netstats{Mode: mode, Streams: make([]*Stream, 0, len(streams))}
+	for _, s := range streams {
+		stream := &Stream{Operation: s.Description, ID: s.PlanID, Peers: make([]*StreamPeer, 0)}
+		for _, session := range s.Sessions {
+			peer := &StreamPeer{Address: strings.TrimPrefix(session.Peer, "/")}
+			for _, sum := range session.ReceivingSummaries {
+				peer.ReceivingFiles += sum.Files
+				peer.ReceivingBytes += sum.TotalSize
+			}
+			for _, sum := range session.SendingSummaries {
+				peer.SendingFiles += sum.Files
+				peer.SendingBytes += sum.TotalSize
+			}
+			for _, f := range session.ReceivingFiles {
+				peer.ReceivedBytes += f.CurrentBytes
+				if f.CurrentBytes == f.TotalBytes {
+					peer.ReceivedFiles++
+				}
+			}
+			for _, f := range session.SendingFiles {
+				peer.SentBytes += f.CurrentBytes
+				if f.CurrentBytes == f.TotalBytes {
+					peer.SentFiles++
+				}
+			}
+			stream.Peers = append(stream.Peers, peer)
+		}
+		n.Streams = append(n.Streams, stream)
+	}
+	return n, nil
+}
+
+// metric is one attribute of the metrics matching an mbean pattern.
+type metric struct {
+	pattern   string
+	attribute string
+}
+
+func tableMetric(name, attribute string) metric {
+	return metric{"org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=" + name, attribute}
+}
+
+func threadPoolMetric(name, attribute string) metric {
+	return metric{"org.apache.cassandra.metrics:type=ThreadPools,path=*,scope=*,name=" + name, attribute}
+}
+
+// readMetrics reads the metrics in one request, each one's values are keyed by
+// the properties of the mbean names matching its pattern.
+func (j *jolokia) readMetrics(metrics ...metric) ([][]metricValue, error) {
+	reqs := make([]jolokiaRequest, len(metrics))
+	raw := make([]map[string]map[string]float64, len(metrics))
+	dst := make([]interface{}, len(metrics))
+	for i, m := range metrics {
+		reqs[i] = readRequest(m.pattern, m.attribute)
+		dst[i] = &raw[i]
+	}
+	if err := j.call(reqs, dst...); err != nil {
+		return nil, err
+	}
+	values := make([][]metricValue, len(metrics))
+	for i, m := range metrics {
+		names := make([]string, 0, len(raw[i]))
+		for name := range raw[i] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			values[i] = append(values[i], metricValue{mbeanProperties(name), raw[i][name][m.attribute]})
+		}
+	}
+	return values, nil
+}
+
+type metricValue struct {
+	props map[string]string
+	value float64
+}
+
+// mbeanProperties splits the key properties of an mbean name.
+func mbeanProperties(name string) map[string]string {
+	props := make(map[string]string)
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, prop := range strings.Split(name, ",") {
+		if kv := strings.SplitN(prop, "=", 2); len(kv) == 2 {
+			props[kv[0]] = kv[1]
+		}
+	}
+	return props
+}
+
+// Tablestats reads the table metrics, latencies are left at zero as jolokia
+// exposes them as histograms.
+func (j *jolokia) Tablestats() (*Tablestats, error) {
+	values, err := j.readMetrics(
+		tableMetric("LiveSSTableCount", "Value"),
+		tableMetric("LiveDiskSpaceUsed", "Count"),
+		tableMetric("TotalDiskSpaceUsed", "Count"),
+		tableMetric("SnapshotsSize", "Value"),
+		tableMetric("EstimatedPartitionCount", "Value"),
+		tableMetric("ReadLatency", "Count"),
+		tableMetric("WriteLatency", "Count"),
+		tableMetric("PendingFlushes", "Count"),
+		tableMetric("MaxPartitionSize", "Value"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tablestats{Keyspaces: make([]*KeyspaceStats, 0)}
+	tables := make(map[string]*TableStats)
+	table := func(props map[string]string) *TableStats {
+		key := props["keyspace"] + "." + props["scope"]
+		if ts, ok := tables[key]; ok {
+			return ts
+		}
+		var ks *KeyspaceStats
+		for _, k := range t.Keyspaces {
+			if k.Name == props["keyspace"] {
+				ks = k
+			}
+		}
+		if ks == nil {
+			ks = &KeyspaceStats{Name: props["keyspace"], Tables: make([]*TableStats, 0)}
+			t.Keyspaces = append(t.Keyspaces, ks)
+		}
+		ts := &TableStats{Name: props["scope"], Stats: make(map[string]string)}
+		ks.Tables = append(ks.Tables, ts)
+		tables[key] = ts
+		return ts
+	}
+	fields := []func(ts *TableStats, v int64){
+		func(ts *TableStats, v int64) { ts.SSTableCount = v },
+		func(ts *TableStats, v int64) { ts.SpaceUsedLive = v },
+		func(ts *TableStats, v int64) { ts.SpaceUsedTotal = v },
+		func(ts *TableStats, v int64) { ts.SpaceUsedBySnapshots = v },
+		func(ts *TableStats, v int64) { ts.Partitions = v },
+		func(ts *TableStats, v int64) { ts.LocalReadCount = v },
+		func(ts *TableStats, v int64) { ts.LocalWriteCount = v },
+		func(ts *TableStats, v int64) { ts.PendingFlushes = v },
+		func(ts *TableStats, v int64) { ts.MaxPartitionBytes = v },
+	}
+	for i, set := range fields {
+		for _, v := range values[i] {
+			set(table(v.props), int64(v.value))
+		}
+	}
+	for _, ks := range t.Keyspaces {
+		for _, ts := range ks.Tables {
+			ks.ReadCount += ts.LocalReadCount
+			ks.WriteCount += ts.LocalWriteCount
+			ks.PendingFlushes += ts.PendingFlushes
+		}
+	}
+	return t, nil
+}
+
+func (j *jolokia) Tpstats() (*Tpstats, error) {
+	values, err := j.readMetrics(
+		threadPoolMetric("ActiveTasks", "Value"),
+		threadPoolMetric("PendingTasks", "Value"),
+		threadPoolMetric("CompletedTasks", "Value"),
+		threadPoolMetric("CurrentlyBlockedTasks", "Count"),
+		threadPoolMetric("TotalBlockedTasks", "Count"),
+		metric{"org.apache.cassandra.metrics:type=DroppedMessage,scope=*,name=Dropped", "Count"},
+	)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tpstats{Pools: make([]*ThreadPool, 0), Dropped: make(map[string]int64)}
+	pools := make(map[string]*ThreadPool)
+	fields := []func(p *ThreadPool, v int64){
+		func(p *ThreadPool, v int64) { p.Active = v },
+		func(p *ThreadPool, v int64) { p.Pending = v },
+		func(p *ThreadPool, v int64) { p.Completed = v },
+		func(p *ThreadPool, v int64) { p.Blocked = v },
+		func(p *ThreadPool, v int64) { p.AllTimeBlocked = v },
+	}
+	for i, set := range fields {
+		for _, v := range values[i] {
+			name := v.props["scope"]
+			pool, ok := pools[name]
+			if !ok {
+				pool = &ThreadPool{Name: name}
+				pools[name] = pool
+				t.Pools = append(t.Pools, pool)
+			}
+			set(pool, int64(v.value))
+		}
+	}
+	sort.Slice(t.Pools, func(i, k int) bool { return t.Pools[i].Name < t.Pools[k].Name })
+	for _, v := range values[len(fields)] {
+		t.Dropped[v.props["scope"]] = int64(v.value)
+	}
+	return t, nil
+}
+
+func (j *jolokia) Compactionstats() (*CompactionStats, error) {
+	var pending int64
+	var compactions []map[string]string
+	err := j.call([]jolokiaRequest{
+		readRequest("org.apache.cassandra.metrics:type=Compaction,name=PendingTasks", "Value"),
+		readRequest(compactionManagerMBean, "Compactions"),
+	}, &pending, &compactions)
+	if err != nil {
+		return nil, err
+	}
+	c := &CompactionStats{PendingTasks: pending, Pending: make(map[string]int64), Compactions: make([]*Compaction, 0, len(compactions))}
+	for _, m := range compactions {
+		compaction := &Compaction{
+			ID:       m["compactionId"],
+			Type:     m["taskType"],
+			Keyspace: m["keyspace"],
+			Table:    m["columnfamily"],
+			Unit:     m["unit"],
+		}
+		compaction.Completed, _ = strconv.ParseInt(m["completed"], 10, 64)
+		compaction.Total, _ = strconv.ParseInt(m["total"], 10, 64)
+		if compaction.Total > 0 {
+			compaction.Progress = float64(compaction.Completed) / float64(compaction.Total) * 100
+		}
+		c.Compactions = append(c.Compactions, compaction)
+	}
+	return c, nil
+}
@@ -0,0 +1,284 @@
+package nodetool
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeJolokia serves attributes and records executed operations.
+type fakeJolokia struct {
+	attributes map[string]map[string]interface{}
+	operations map[string]interface{}
+	executed   []string
+}
+
+func (f *fakeJolokia) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var reqs []jolokiaRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	responses := make([]map[string]interface{}, 0, len(reqs))
+	for _, req := range reqs {
+		res := map[string]interface{}{"status": 200}
+		switch req.Type {
+		case "read":
+			attrs := f.attributes[req.MBean]
+			switch a := req.Attribute.(type) {
+			case string:
+				res["value"] = attrs[a]
+			case []interface{}:
+				value := make(map[string]interface{})
+				for _, name := range a {
+					value[name.(string)] = attrs[name.(string)]
+				}
+				res["value"] = value
+			}
+		case "exec":
+			call := fmt.Sprintf("%s%v", req.Operation, req.Arguments)
+			f.executed = append(f.executed, call)
+			if v, ok := f.operations[call]; ok {
+				res["value"] = v
+			} else if v, ok := f.operations[req.Operation]; ok {
+				res["value"] = v
+			} else {
+				res = map[string]interface{}{"status": 404, "error": "no operation " + call}
+			}
+		}
+		responses = append(responses, res)
+	}
+	json.NewEncoder(w).Encode(responses)
+}
+
+func newFakeJolokia() *fakeJolokia {
+	return &fakeJolokia{
+		attributes: map[string]map[string]interface{}{
+			storageServiceMBean: {
+				"LocalHostId":             "5b1a7e20-f6a6-11e5-a8a4-c1d3a4c7e9a1",
+				"GossipRunning":           true,
+				"RPCServerRunning":        false,
+				"NativeTransportRunning":  true,
+				"LoadString":              "1.2 GB",
+				"CurrentGenerationNumber": 1460628403,
+				"ClusterName":             "challenge-cassandra",
+				"LiveNodes":               []string{"172.18.35.82", "172.18.36.63"},
+				"UnreachableNodes":        []string{"172.18.37.220"},
+				"JoiningNodes":            []string{"172.18.36.63"},
+				"LeavingNodes":            []string{},
+				"MovingNodes":             []string{},
+				"LoadMap":                 map[string]string{"172.18.35.82": "1.2 GB", "172.18.36.63": "10 KB", "172.18.37.220": "1.1 GB"},
+				"HostIdMap": map[string]string{
+					"172.18.35.82":  "5b1a7e20-f6a6-11e5-a8a4-c1d3a4c7e9a1",
+					"172.18.36.63":  "0e9f5c10-f6a6-11e5-a8a4-c1d3a4c7e9a1",
+					"172.18.37.220": "7c2d1e40-f6a6-11e5-a8a4-c1d3a4c7e9a1",
+				},
+				"Ownership":          map[string]float64{"/172.18.35.82": 0.5, "/172.18.37.220": 0.5},
+				"TokenToEndpointMap": map[string]string{"-100": "172.18.35.82", "100": "172.18.37.220", "200": "172.18.35.82"},
+			},
+			snitchInfoMBean: {"Datacenter": "us-west", "Rack": "1a"},
+			memoryMBean:     {"HeapMemoryUsage": map[string]int64{"used": 512 * 1024 * 1024, "max": 2048 * 1024 * 1024}},
+			runtimeMBean:    {"Uptime": 3600 * 1000},
+			exceptionsMBean: {"Count": 2},
+		},
+		operations: map[string]interface{}{
+			"getDatacenter":          "us-west",
+			"getRack[172.18.37.220]": "1c",
+			"getRack":                "1a",
+			"takeSnapshot(java.lang.String,[Ljava.lang.String;)": nil,
+		},
+	}
+}
+
+func TestJolokiaInfo(t *testing.T) {
+	server := httptest.NewServer(newFakeJolokia())
+	defer server.Close()
+	cfg := DefaultConfig()
+	cfg.JolokiaURL = server.URL + "/jolokia"
+	info, err := New(cfg).Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != "5b1a7e20-f6a6-11e5-a8a4-c1d3a4c7e9a1" || !info.GossipActive || info.ThriftActive || !info.NativeTransportActive {
+		t.Fatalf("unexpected info %+v", info)
+	}
+	if info.HeapUsage != 25 || info.Uptime != "3600" || info.DataCenter != "us-west" || info.Exceptions != 2 {
+		t.Fatalf("unexpected info %+v", info)
+	}
+}
+
+func TestJolokiaStatus(t *testing.T) {
+	server := httptest.NewServer(newFakeJolokia())
+	defer server.Close()
+	cfg := DefaultConfig()
+	cfg.JolokiaURL = server.URL
+	status, err := New(cfg).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Datacenters) != 1 || len(status.Datacenters[0].Nodes) != 3 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	nodes := status.Datacenters[0].Nodes
+	want := []Node{
+		{State: "UN", Address: "172.18.35.82", Load: "1.2 GB", Tokens: "2", Owns: "50.0%", HostID: "5b1a7e20-f6a6-11e5-a8a4-c1d3a4c7e9a1", Rack: "1a"},
+		{State: "UJ", Address: "172.18.36.63", Load: "10 KB", Tokens: "0", Owns: "?", HostID: "0e9f5c10-f6a6-11e5-a8a4-c1d3a4c7e9a1", Rack: "1a"},
+		{State: "DN", Address: "172.18.37.220", Load: "1.1 GB", Tokens: "1", Owns: "50.0%", HostID: "7c2d1e40-f6a6-11e5-a8a4-c1d3a4c7e9a1", Rack: "1c"},
+	}
+	for i := range want {
+		if nodes[i] != want[i] {
+			t.Fatalf("node %d = %+v, want %+v", i, nodes[i], want[i])
+		}
+	}
+}
+
+func TestJolokiaStatusDecodeError(t *testing.T) {
+	fake := newFakeJolokia()
+	fake.operations["getDatacenter"] = 12
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	cfg := DefaultConfig()
+	cfg.JolokiaURL = server.URL
+	if status, err := New(cfg).Status(); err == nil {
+		t.Fatalf("expected a decoding error, got %+v", status)
+	}
+}
+
+func TestJolokiaSnapshot(t *testing.T) {
+	fake := newFakeJolokia()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	cfg := DefaultConfig()
+	cfg.JolokiaURL = server.URL
+	snapshot, err := New(cfg).Snapshot("1460628403086", []string{"app"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Name != "1460628403086" {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+	if len(fake.executed) != 1 || fake.executed[0] != "takeSnapshot(java.lang.String,[Ljava.lang.String;)[1460628403086 [app]]" {
+		t.Fatalf("unexpected operations %q", fake.executed)
+	}
+	if err := New(cfg).Drain(); err == nil {
+		t.Fatal("expected a failed operation to return an error")
+	}
+}
+
+func TestJolokiaDrainTimeout(t *testing.T) {
+	fake := newFakeJolokia()
+	fake.operations["drain"] = nil
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		fake.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	cfg := DefaultConfig()
+	cfg.JolokiaURL = server.URL
+	j := newJolokia(cfg)
+	j.client.Timeout = 10 * time.Millisecond
+	if _, err := j.Info(); err == nil {
+		t.Fatal("expected reads to time out")
+	}
+	if err := j.Drain(); err != nil {
+		t.Fatalf("drain timed out with reads: %v", err)
+	}
+}
+
+func TestJolokiaListSnapshots(t *testing.T) {
+	fake := newFakeJolokia()
+	fake.attributes[storageServiceMBean]["SnapshotDetails"] = map[string]interface{}{
+		"1460628403086": map[string]interface{}{
+			"1460628403086": map[string]interface{}{
+				"app": map[string]interface{}{
+					"events": map[string]interface{}{
+						"Snapshot name":      "1460628403086",
+						"Keyspace name":      "app",
+						"Column family name": "events",
+						"True size":          "1.5 MB",
+						"Size on disk":       "2 MB",
+					},
+				},
+			},
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	cfg := DefaultConfig()
+	cfg.JolokiaURL = server.URL
+	details, err := New(cfg).ListSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SnapshotDetails{Name: "1460628403086", Keyspace: "app", Table: "events", TrueSize: 1572864, SizeOnDisk: 2097152}
+	if len(details) != 1 || *details[0] != want {
+		t.Fatalf("unexpected details %+v", details)
+	}
+}
+
+func TestJolokiaTpstats(t *testing.T) {
+	fake := newFakeJolokia()
+	pool := func(name, attribute string, values map[string]int) {
+		pattern := threadPoolMetric(name, attribute).pattern
+		value := make(map[string]interface{})
+		for scope, v := range values {
+			value["org.apache.cassandra.metrics:name="+name+",path=internal,scope="+scope+",type=ThreadPools"] = map[string]int{attribute: v}
+		}
+		fake.attributes[pattern] = map[string]interface{}{attribute: value}
+	}
+	pool("ActiveTasks", "Value", map[string]int{"CompactionExecutor": 2, "MemtableFlushWriter": 0})
+	pool("PendingTasks", "Value", map[string]int{"CompactionExecutor": 14, "MemtableFlushWriter": 1})
+	pool("CompletedTasks", "Value", map[string]int{"CompactionExecutor": 93208, "MemtableFlushWriter": 1042})
+	pool("CurrentlyBlockedTasks", "Count", map[string]int{"CompactionExecutor": 0, "MemtableFlushWriter": 0})
+	pool("TotalBlockedTasks", "Count", map[string]int{"CompactionExecutor": 0, "MemtableFlushWriter": 3})
+	fake.attributes["org.apache.cassandra.metrics:type=DroppedMessage,scope=*,name=Dropped"] = map[string]interface{}{
+		"Count": map[string]interface{}{
+			"org.apache.cassandra.metrics:name=Dropped,scope=MUTATION,type=DroppedMessage": map[string]int{"Count": 12},
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	cfg := DefaultConfig()
+	cfg.JolokiaURL = server.URL
+	stats, err := New(cfg).Tpstats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ThreadPool{Name: "CompactionExecutor", Active: 2, Pending: 14, Completed: 93208}
+	if len(stats.Pools) != 2 || *stats.Pools[0] != want || stats.Pool("MemtableFlushWriter").AllTimeBlocked != 3 {
+		t.Fatalf("unexpected pools %+v", stats.Pools)
+	}
+	if stats.Dropped["MUTATION"] != 12 {
+		t.Fatalf("unexpected dropped %v", stats.Dropped)
+	}
+}
+
+func TestJolokiaCompactionstats(t *testing.T) {
+	fake := newFakeJolokia()
+	fake.attributes["org.apache.cassandra.metrics:type=Compaction,name=PendingTasks"] = map[string]interface{}{"Value": 5}
+	fake.attributes[compactionManagerMBean] = map[string]interface{}{
+		"Compactions": []map[string]string{{
+			"compactionId": "6a8c1b00-f6a6-11e5-a8a4-c1d3a4c7e9a1",
+			"taskType":     "Compaction",
+			"keyspace":     "app",
+			"columnfamily": "events",
+			"completed":    "268435456",
+			"total":        "1073741824",
+			"unit":         "bytes",
+		}},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	cfg := DefaultConfig()
+	cfg.JolokiaURL = server.URL
+	stats, err := New(cfg).Compactionstats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.PendingTasks != 5 || len(stats.Compactions) != 1 || stats.Compactions[0].Progress != 25 || stats.Remaining() != 805306368 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
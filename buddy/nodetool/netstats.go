@@ -0,0 +1,85 @@
+package nodetool
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Netstats is the streaming state of a node.
+type Netstats struct {
+	// Mode is the operation mode, NORMAL, JOINING, LEAVING, DECOMMISSIONED...
+	Mode    string
+	Streams []*Stream
+}
+
+// Stream is a streaming session like a bootstrap, rebuild or repair.
+type Stream struct {
+	Operation string
+	ID        string
+	Peers     []*StreamPeer
+}
+
+// StreamPeer is the progress of streaming with one peer.
+type StreamPeer struct {
+	Address        string
+	ReceivingFiles int64
+	ReceivingBytes int64
+	ReceivedFiles  int64
+	ReceivedBytes  int64
+	SendingFiles   int64
+	SendingBytes   int64
+	SentFiles      int64
+	SentBytes      int64
+}
+
+var netstatsModeRegex = regexp.MustCompile(`^Mode: (\S+)$`)
+var netstatsStreamRegex = regexp.MustCompile(`^(\S+(?: \S+)*) ([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})$`)
+var netstatsPeerRegex = regexp.MustCompile(`^\s+/(\S+)$`)
+var netstatsReceivingRegex = regexp.MustCompile(`^\s+Receiving (\d+) files, (\d+) bytes total\. Already received (\d+) files, (\d+) bytes total`)
+var netstatsSendingRegex = regexp.MustCompile(`^\s+Sending (\d+) files, (\d+) bytes total\. Already sent (\d+) files, (\d+) bytes total`)
+
+func NewNetstats(d []byte) *Netstats {
+	n := &Netstats{Streams: make([]*Stream, 0)}
+	var stream *Stream
+	var peer *StreamPeer
+	for _, line := range strings.Split(string(d), "\n") {
+		if parts := netstatsModeRegex.FindStringSubmatch(line); parts != nil {
+			n.Mode = parts[1]
+		} else if parts := netstatsStreamRegex.FindStringSubmatch(line); parts != nil {
+			stream = &Stream{Operation: parts[1], ID: parts[2], Peers: make([]*StreamPeer, 0)}
+			n.Streams = append(n.Streams, stream)
+			peer = nil
+		} else if parts := netstatsPeerRegex.FindStringSubmatch(line); parts != nil && stream != nil {
+			peer = &StreamPeer{Address: parts[1]}
+			stream.Peers = append(stream.Peers, peer)
+		} else if parts := netstatsReceivingRegex.FindStringSubmatch(line); parts != nil && peer != nil {
+			peer.ReceivingFiles, peer.ReceivingBytes, peer.ReceivedFiles, peer.ReceivedBytes = parseCounts(parts[1:])
+		} else if parts := netstatsSendingRegex.FindStringSubmatch(line); parts != nil && peer != nil {
+			peer.SendingFiles, peer.SendingBytes, peer.SentFiles, peer.SentBytes = parseCounts(parts[1:])
+		} else if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			// read repair and message pool statistics follow the streams
+			stream, peer = nil, nil
+		}
+	}
+	return n
+}
+
+func parseCounts(parts []string) (a, b, c, d int64) {
+	a, _ = strconv.ParseInt(parts[0], 10, 64)
+	b, _ = strconv.ParseInt(parts[1], 10, 64)
+	c, _ = strconv.ParseInt(parts[2], 10, 64)
+	d, _ = strconv.ParseInt(parts[3], 10, 64)
+	return
+}
+
+// Received returns the bytes received and the bytes to receive over all streams.
+func (n *Netstats) Received() (received, total int64) {
+	for _, stream := range n.Streams {
+		for _, peer := range stream.Peers {
+			received += peer.ReceivedBytes
+			total += peer.ReceivingBytes
+		}
+	}
+	return received, total
+}
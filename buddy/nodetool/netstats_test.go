@@ -0,0 +1,51 @@
+package nodetool
+
+import "testing"
+
+var testNetstats = []byte(`Mode: JOINING
+Bootstrap 6a8c1b00-f6a6-11e5-a8a4-c1d3a4c7e9a1
+    /172.18.35.82
+        Receiving 12 files, 1073741824 bytes total. Already received 3 files, 268435456 bytes total
+            /var/lib/cassandra/data/app/events-5b1a7e20f6a611e5a8a4c1d3a4c7e9a1/tmp-mc-4-big-Data.db 1048576/4194304 bytes(25%) received from idx:0/172.18.35.82
+    /172.18.36.63
+        Receiving 5 files, 52428800 bytes total. Already received 5 files, 52428800 bytes total
+Read Repair Statistics:
+Attempted: 0
+Mismatch (Blocking): 0
+Mismatch (Background): 0
+Pool Name                    Active   Pending      Completed   Dropped
+Large messages                  n/a         0              0         0
+Small messages                  n/a         0            123         0
+Gossip messages                 n/a         0            456         0
+`)
+
+var testNetstatsIdle = []byte(`Mode: NORMAL
+Not sending any streams.
+Read Repair Statistics:
+Attempted: 0
+Mismatch (Blocking): 0
+Mismatch (Background): 0
+`)
+
+func TestNewNetstats(t *testing.T) {
+	n := NewNetstats(testNetstats)
+	if n.Mode != "JOINING" {
+		t.Fatalf("unexpected mode %s", n.Mode)
+	}
+	if len(n.Streams) != 1 || n.Streams[0].Operation != "Bootstrap" || len(n.Streams[0].Peers) != 2 {
+		t.Fatalf("unexpected streams %+v", n.Streams)
+	}
+	peer := n.Streams[0].Peers[0]
+	if peer.Address != "172.18.35.82" || peer.ReceivingFiles != 12 || peer.ReceivedFiles != 3 {
+		t.Fatalf("unexpected peer %+v", peer)
+	}
+	received, total := n.Received()
+	if received != 268435456+52428800 || total != 1073741824+52428800 {
+		t.Fatalf("unexpected progress %d/%d", received, total)
+	}
+
+	idle := NewNetstats(testNetstatsIdle)
+	if idle.Mode != "NORMAL" || len(idle.Streams) != 0 {
+		t.Fatalf("unexpected idle netstats %+v", idle)
+	}
+}
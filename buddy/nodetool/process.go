@@ -2,8 +2,11 @@ package nodetool
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 )
 
 type Nodetool interface {
@@ -12,18 +15,53 @@ type Nodetool interface {
 	ClusterInfo() (*ClusterInfo, error)
 	Snapshot(name string, keyspaces, tables []string) (*Snapshot, error)
 	ClearSnapshot(name string, keyspaces, tables []string) error
+	ListSnapshots() ([]*SnapshotDetails, error)
 	Refresh(keyspace, table string) error
+	Drain() error
+	Netstats() (*Netstats, error)
+	Tablestats() (*Tablestats, error)
+	Tpstats() (*Tpstats, error)
+	Compactionstats() (*CompactionStats, error)
+}
+
+// Config is how nodetool reaches the node over JMX.
+type Config struct {
+	// Binary is the nodetool executable.
+	Binary string
+	Host   string
+	Port   int
+	// Username and PasswordFile are set when JMX authentication is enabled.
+	Username     string
+	PasswordFile string
+	SSL          bool
+	// JolokiaURL switches to the jolokia agent, like http://127.0.0.1:8778/jolokia
+	JolokiaURL      string
+	JolokiaUsername string
+	JolokiaPassword string
+}
+
+// DefaultConfig returns a config for a local node on the default JMX port.
+func DefaultConfig() *Config {
+	return &Config{
+		Binary: "/usr/local/bin/nodetool",
+		Host:   "127.0.0.1",
+		Port:   7199,
+	}
 }
 
 type nodetool struct {
-	Nodetool string
-	Host     string
-	Port     string
+	cfg *Config
 }
 
-// New returns nodetool instance.
-func New() Nodetool {
-	return &nodetool{}
+// New returns nodetool instance, with cfg.JolokiaURL set the node is reached
+// through jolokia instead of the nodetool binary.
+func New(cfg *Config) Nodetool {
+	if cfg.JolokiaURL != "" {
+		return newJolokia(cfg)
+	}
+	return &nodetool{
+		cfg: cfg,
+	}
 }
 
 func (n *nodetool) Status() (*Status, error) {
@@ -67,6 +105,15 @@ func (n *nodetool) ClearSnapshot(name string, keyspaces, tables []string) error
 	return err
 }
 
+// ListSnapshots lists the snapshots on the node by table.
+func (n *nodetool) ListSnapshots() ([]*SnapshotDetails, error) {
+	data, err := n.exec([]string{"listsnapshots"})
+	if err != nil {
+		return nil, err
+	}
+	return NewSnapshotDetails(data), nil
+}
+
 func (n *nodetool) Refresh(keyspace, table string) error {
 	if keyspace == "" || table == "" {
 		return errors.New("Refresh requires keyspace and column family")
@@ -76,6 +123,48 @@ func (n *nodetool) Refresh(keyspace, table string) error {
 	return err
 }
 
+// Drain flushes memtables and stops accepting writes, the node has to be
+// restarted afterwards.
+func (n *nodetool) Drain() error {
+	out, err := n.exec([]string{"drain"})
+	if err != nil {
+		return fmt.Errorf("nodetool drain failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (n *nodetool) Netstats() (*Netstats, error) {
+	data, err := n.exec([]string{"netstats"})
+	if err != nil {
+		return nil, err
+	}
+	return NewNetstats(data), nil
+}
+
+func (n *nodetool) Tablestats() (*Tablestats, error) {
+	data, err := n.exec([]string{"tablestats"})
+	if err != nil {
+		return nil, err
+	}
+	return NewTablestats(data), nil
+}
+
+func (n *nodetool) Tpstats() (*Tpstats, error) {
+	data, err := n.exec([]string{"tpstats"})
+	if err != nil {
+		return nil, err
+	}
+	return NewTpstats(data), nil
+}
+
+func (n *nodetool) Compactionstats() (*CompactionStats, error) {
+	data, err := n.exec([]string{"compactionstats"})
+	if err != nil {
+		return nil, err
+	}
+	return NewCompactionStats(data), nil
+}
+
 func (n *nodetool) Info() (*Info, error) {
 	data, err := n.exec([]string{"info"})
 	if err != nil {
@@ -92,8 +181,29 @@ func (n *nodetool) ClusterInfo() (*ClusterInfo, error) {
 	return NewClusterInfo(data)
 }
 
+// connectionArgs are the flags that point nodetool at the node.
+func (n *nodetool) connectionArgs() []string {
+	args := make([]string, 0)
+	if n.cfg.Host != "" {
+		args = append(args, "-h", n.cfg.Host)
+	}
+	if n.cfg.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(n.cfg.Port))
+	}
+	if n.cfg.Username != "" {
+		args = append(args, "-u", n.cfg.Username)
+	}
+	if n.cfg.PasswordFile != "" {
+		args = append(args, "-pwf", n.cfg.PasswordFile)
+	}
+	if n.cfg.SSL {
+		args = append(args, "--ssl")
+	}
+	return args
+}
+
 func (n *nodetool) exec(args []string) ([]byte, error) {
-	cmd := exec.Command("/usr/local/bin/nodetool", args...)
+	cmd := exec.Command(n.cfg.Binary, append(n.connectionArgs(), args...)...)
 	cmd.Env = os.Environ()
 	//log.Printf("cmd: %#v", cmd)
 	return cmd.CombinedOutput()
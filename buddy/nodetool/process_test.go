@@ -0,0 +1,19 @@
+package nodetool
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConnectionArgs(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 7299
+	cfg.Username = "cassandra"
+	cfg.PasswordFile = "/etc/cassandra/jmxremote.password"
+	cfg.SSL = true
+	n := New(cfg).(*nodetool)
+	want := []string{"-h", "127.0.0.1", "-p", "7299", "-u", "cassandra", "-pwf", "/etc/cassandra/jmxremote.password", "--ssl"}
+	if args := n.connectionArgs(); !reflect.DeepEqual(args, want) {
+		t.Fatalf("unexpected args %q", args)
+	}
+}
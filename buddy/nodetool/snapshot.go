@@ -1,10 +1,105 @@
 package nodetool
 
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
 type Snapshot struct {
 	Name string
+	// Path is the directory name the snapshot was written to under each table.
 	Path string
+	// Keyspaces are the snapshotted keyspaces, empty when all were.
+	Keyspaces []string
 }
 
+var snapshotRequestRegex = regexp.MustCompile(`^Requested creating snapshot\(s\) for \[(.*)\] with snapshot name \[(.+)\]`)
+var snapshotDirRegex = regexp.MustCompile(`^Snapshot directory: (.+)$`)
+
 func NewSnapshot(d []byte) *Snapshot {
-	return &Snapshot{}
+	s := &Snapshot{Keyspaces: make([]string, 0)}
+	for _, line := range strings.Split(string(d), "\n") {
+		line = strings.TrimSpace(line)
+		if parts := snapshotRequestRegex.FindStringSubmatch(line); parts != nil {
+			s.Name = parts[2]
+			if parts[1] != "all keyspaces" {
+				for _, ks := range strings.Split(parts[1], ",") {
+					if ks = strings.TrimSpace(ks); ks != "" {
+						s.Keyspaces = append(s.Keyspaces, ks)
+					}
+				}
+			}
+		} else if parts := snapshotDirRegex.FindStringSubmatch(line); parts != nil {
+			s.Path = parts[1]
+		}
+	}
+	return s
+}
+
+// SnapshotDetails is the size of one table in a snapshot. TrueSize is what the
+// snapshot alone keeps on disk, files shared with live sstables are not counted.
+type SnapshotDetails struct {
+	Name       string `json:"name"`
+	Keyspace   string `json:"keyspace"`
+	Table      string `json:"table"`
+	TrueSize   int64  `json:"true_size"`
+	SizeOnDisk int64  `json:"size_on_disk"`
+}
+
+var snapshotDetailsRegex = regexp.MustCompile(`^(\S+)\s+(\S+)\s+(\S+)\s+([0-9.,]+ \S+)\s+([0-9.,]+ \S+)\s*$`)
+
+// NewSnapshotDetails parses nodetool listsnapshots.
+func NewSnapshotDetails(d []byte) []*SnapshotDetails {
+	details := make([]*SnapshotDetails, 0)
+	for _, line := range strings.Split(string(d), "\n") {
+		parts := snapshotDetailsRegex.FindStringSubmatch(line)
+		if parts == nil {
+			continue
+		}
+		trueSize, ok1 := ParseSize(parts[4])
+		sizeOnDisk, ok2 := ParseSize(parts[5])
+		if !ok1 || !ok2 {
+			// the header line matches the shape but not the sizes
+			continue
+		}
+		details = append(details, &SnapshotDetails{
+			Name:       parts[1],
+			Keyspace:   parts[2],
+			Table:      parts[3],
+			TrueSize:   trueSize,
+			SizeOnDisk: sizeOnDisk,
+		})
+	}
+	return details
+}
+
+var sizeUnits = map[string]float64{
+	"bytes": 1,
+	"B":     1,
+	"KB":    1 << 10,
+	"KiB":   1 << 10,
+	"MB":    1 << 20,
+	"MiB":   1 << 20,
+	"GB":    1 << 30,
+	"GiB":   1 << 30,
+	"TB":    1 << 40,
+	"TiB":   1 << 40,
+}
+
+// ParseSize parses sizes nodetool prints like "1.2 MB" or "0 bytes" into bytes.
+func ParseSize(s string) (int64, bool) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return 0, false
+	}
+	unit, ok := sizeUnits[fields[1]]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(strings.Replace(fields[0], ",", "", -1), 64)
+	if err != nil {
+		return 0, false
+	}
+	return int64(n * unit), true
 }
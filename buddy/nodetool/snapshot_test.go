@@ -0,0 +1,54 @@
+package nodetool
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testSnapshot = []byte(`Requested creating snapshot(s) for [app, system_auth] with snapshot name [1460628403086] and options {skipFlush=false}
+Snapshot directory: 1460628403086
+`)
+
+var testSnapshotAll = []byte(`Requested creating snapshot(s) for [all keyspaces] with snapshot name [1460628403086]
+Snapshot directory: 1460628403086
+`)
+
+var testListSnapshots = []byte(`Snapshot Details: 
+Snapshot name Keyspace name Column family name True size Size on disk
+1460628403086 app           events             1.5 MB    2 MB         
+1460628403086 system_auth   roles              0 bytes   5.12 KB      
+1460541987342 app           events             1,024 bytes 1 KiB        
+
+Total TrueDiskSpaceUsed: 1.5 MB
+
+`)
+
+func TestNewSnapshot(t *testing.T) {
+	s := NewSnapshot(testSnapshot)
+	if s.Name != "1460628403086" || s.Path != "1460628403086" || !reflect.DeepEqual(s.Keyspaces, []string{"app", "system_auth"}) {
+		t.Fatalf("unexpected snapshot %+v", s)
+	}
+	if all := NewSnapshot(testSnapshotAll); len(all.Keyspaces) != 0 || all.Name != "1460628403086" {
+		t.Fatalf("unexpected snapshot of all keyspaces %+v", all)
+	}
+}
+
+func TestNewSnapshotDetails(t *testing.T) {
+	details := NewSnapshotDetails(testListSnapshots)
+	if len(details) != 3 {
+		t.Fatalf("expected 3 tables, got %d", len(details))
+	}
+	want := &SnapshotDetails{Name: "1460628403086", Keyspace: "app", Table: "events", TrueSize: 1572864, SizeOnDisk: 2097152}
+	if *details[0] != *want {
+		t.Fatalf("unexpected details %+v", details[0])
+	}
+	if details[1].TrueSize != 0 || details[1].SizeOnDisk != 5242 {
+		t.Fatalf("unexpected sizes %+v", details[1])
+	}
+	if details[2].TrueSize != 1024 || details[2].SizeOnDisk != 1024 {
+		t.Fatalf("unexpected sizes %+v", details[2])
+	}
+	if len(NewSnapshotDetails([]byte("There are no snapshots\n"))) != 0 {
+		t.Fatal("expected no snapshots")
+	}
+}
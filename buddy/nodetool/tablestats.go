@@ -0,0 +1,154 @@
+package nodetool
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Tablestats are the statistics of every keyspace and table of a node.
+type Tablestats struct {
+	Keyspaces []*KeyspaceStats
+}
+
+type KeyspaceStats struct {
+	Name           string
+	ReadCount      int64
+	ReadLatency    float64
+	WriteCount     int64
+	WriteLatency   float64
+	PendingFlushes int64
+	Tables         []*TableStats
+}
+
+// TableStats are the statistics of one table, latencies are in milliseconds
+// and sizes in bytes. Stats has every line nodetool printed for the table.
+type TableStats struct {
+	Name                 string
+	SSTableCount         int64
+	SpaceUsedLive        int64
+	SpaceUsedTotal       int64
+	SpaceUsedBySnapshots int64
+	Partitions           int64
+	LocalReadCount       int64
+	LocalReadLatency     float64
+	LocalWriteCount      int64
+	LocalWriteLatency    float64
+	PendingFlushes       int64
+	MaxPartitionBytes    int64
+	DroppedMutations     int64
+	Stats                map[string]string
+}
+
+var tablestatsKeyspaceRegex = regexp.MustCompile(`^Keyspace\s*: (\S+)$`)
+var tablestatsTableRegex = regexp.MustCompile(`^\s+(?:Table|Column Family)(?: \(index\))?: (\S+)$`)
+var tablestatsStatRegex = regexp.MustCompile(`^\s+([^:]+): (.+)$`)
+
+func NewTablestats(d []byte) *Tablestats {
+	t := &Tablestats{Keyspaces: make([]*KeyspaceStats, 0)}
+	var ks *KeyspaceStats
+	var table *TableStats
+	for _, line := range strings.Split(string(d), "\n") {
+		if parts := tablestatsKeyspaceRegex.FindStringSubmatch(line); parts != nil {
+			ks = &KeyspaceStats{Name: parts[1], Tables: make([]*TableStats, 0)}
+			t.Keyspaces = append(t.Keyspaces, ks)
+			table = nil
+		} else if parts := tablestatsTableRegex.FindStringSubmatch(line); parts != nil && ks != nil {
+			table = &TableStats{Name: parts[1], Stats: make(map[string]string)}
+			ks.Tables = append(ks.Tables, table)
+		} else if parts := tablestatsStatRegex.FindStringSubmatch(line); parts != nil && ks != nil {
+			name, value := parts[1], strings.TrimSpace(parts[2])
+			if table == nil {
+				ks.setStat(name, value)
+			} else {
+				table.setStat(name, value)
+			}
+		} else if strings.HasPrefix(line, "---") {
+			ks, table = nil, nil
+		}
+	}
+	return t
+}
+
+func (ks *KeyspaceStats) setStat(name, value string) {
+	switch name {
+	case "Read Count":
+		ks.ReadCount = parseCount(value)
+	case "Read Latency":
+		ks.ReadLatency = parseLatency(value)
+	case "Write Count":
+		ks.WriteCount = parseCount(value)
+	case "Write Latency":
+		ks.WriteLatency = parseLatency(value)
+	case "Pending Flushes", "Pending Tasks":
+		ks.PendingFlushes = parseCount(value)
+	}
+}
+
+func (t *TableStats) setStat(name, value string) {
+	t.Stats[name] = value
+	switch name {
+	case "SSTable count":
+		t.SSTableCount = parseCount(value)
+	case "Space used (live)":
+		t.SpaceUsedLive = parseBytes(value)
+	case "Space used (total)":
+		t.SpaceUsedTotal = parseBytes(value)
+	case "Space used by snapshots (total)":
+		t.SpaceUsedBySnapshots = parseBytes(value)
+	case "Number of partitions (estimate)", "Number of keys (estimate)":
+		t.Partitions = parseCount(value)
+	case "Local read count":
+		t.LocalReadCount = parseCount(value)
+	case "Local read latency":
+		t.LocalReadLatency = parseLatency(value)
+	case "Local write count":
+		t.LocalWriteCount = parseCount(value)
+	case "Local write latency":
+		t.LocalWriteLatency = parseLatency(value)
+	case "Pending flushes", "Pending Flushes":
+		t.PendingFlushes = parseCount(value)
+	case "Compacted partition maximum bytes", "Compacted row maximum size":
+		t.MaxPartitionBytes = parseBytes(value)
+	case "Dropped Mutations":
+		t.DroppedMutations = parseCount(value)
+	}
+}
+
+// Table returns the stats of keyspace.table or nil.
+func (t *Tablestats) Table(keyspace, table string) *TableStats {
+	for _, ks := range t.Keyspaces {
+		if ks.Name != keyspace {
+			continue
+		}
+		for _, ts := range ks.Tables {
+			if ts.Name == table {
+				return ts
+			}
+		}
+	}
+	return nil
+}
+
+func parseCount(value string) int64 {
+	n, _ := strconv.ParseInt(value, 10, 64)
+	return n
+}
+
+// parseBytes parses plain byte counts and human readable sizes printed with -H.
+func parseBytes(value string) int64 {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n
+	}
+	n, _ := ParseSize(value)
+	return n
+}
+
+// parseLatency parses "0.123 ms", latencies of tables without requests are NaN.
+func parseLatency(value string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(value, " ms"), 64)
+	if err != nil || f != f {
+		return 0
+	}
+	return f
+}
@@ -0,0 +1,86 @@
+package nodetool
+
+import "testing"
+
+var testTablestats = []byte(`Total number of tables: 38
+----------------
+Keyspace : app
+	Read Count: 734106
+	Read Latency: 0.4123 ms
+	Write Count: 1285391
+	Write Latency: 0.0211 ms
+	Pending Flushes: 0
+		Table: events
+		SSTable count: 12
+		Space used (live): 1073741824
+		Space used (total): 1073741824
+		Space used by snapshots (total): 268435456
+		Off heap memory used (total): 1048576
+		SSTable Compression Ratio: 0.31
+		Number of partitions (estimate): 48211
+		Memtable cell count: 1024
+		Local read count: 734106
+		Local read latency: 0.412 ms
+		Local write count: 1285391
+		Local write latency: 0.021 ms
+		Pending flushes: 1
+		Percent repaired: 0.0
+		Compacted partition minimum bytes: 30
+		Compacted partition maximum bytes: 8409007
+		Compacted partition mean bytes: 22319
+		Average live cells per slice (last five minutes): NaN
+		Dropped Mutations: 12
+
+		Table: users
+		SSTable count: 1
+		Space used (live): 5.2 KiB
+		Space used (total): 5.2 KiB
+		Space used by snapshots (total): 0 bytes
+		Local read count: 0
+		Local read latency: NaN ms
+
+----------------
+Keyspace : system_auth
+	Read Count: 0
+	Read Latency: NaN ms
+	Write Count: 0
+	Write Latency: NaN ms
+	Pending Flushes: 0
+		Table: roles
+		SSTable count: 1
+
+----------------
+`)
+
+func TestNewTablestats(t *testing.T) {
+	stats := NewTablestats(testTablestats)
+	if len(stats.Keyspaces) != 2 {
+		t.Fatalf("expected 2 keyspaces, got %d", len(stats.Keyspaces))
+	}
+	ks := stats.Keyspaces[0]
+	if ks.Name != "app" || ks.ReadCount != 734106 || ks.ReadLatency != 0.4123 || ks.WriteCount != 1285391 || len(ks.Tables) != 2 {
+		t.Fatalf("unexpected keyspace %+v", ks)
+	}
+	events := stats.Table("app", "events")
+	if events == nil {
+		t.Fatal("expected app.events")
+	}
+	if events.SSTableCount != 12 || events.SpaceUsedLive != 1073741824 || events.SpaceUsedBySnapshots != 268435456 ||
+		events.Partitions != 48211 || events.LocalReadLatency != 0.412 || events.PendingFlushes != 1 ||
+		events.MaxPartitionBytes != 8409007 || events.DroppedMutations != 12 {
+		t.Fatalf("unexpected table %+v", events)
+	}
+	if events.Stats["SSTable Compression Ratio"] != "0.31" {
+		t.Fatalf("unexpected stats %v", events.Stats)
+	}
+	users := stats.Table("app", "users")
+	if users.SpaceUsedLive != 5324 || users.LocalReadLatency != 0 {
+		t.Fatalf("unexpected table %+v", users)
+	}
+	if stats.Keyspaces[1].ReadLatency != 0 || stats.Table("system_auth", "roles") == nil {
+		t.Fatalf("unexpected keyspace %+v", stats.Keyspaces[1])
+	}
+	if stats.Table("app", "missing") != nil {
+		t.Fatal("expected no table")
+	}
+}
@@ -0,0 +1,60 @@
+package nodetool
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Tpstats are the thread pools of a node and the messages it dropped.
+type Tpstats struct {
+	Pools []*ThreadPool
+	// Dropped counts dropped messages by type, MUTATION, READ...
+	Dropped map[string]int64
+}
+
+// ThreadPool is one stage of a node.
+type ThreadPool struct {
+	Name           string
+	Active         int64
+	Pending        int64
+	Completed      int64
+	Blocked        int64
+	AllTimeBlocked int64
+}
+
+var tpstatsPoolRegex = regexp.MustCompile(`^(\S+)\s+(\d+|n/a)\s+(\d+|n/a)\s+(\d+|n/a)\s+(\d+|n/a)\s+(\d+|n/a)\s*$`)
+var tpstatsDroppedRegex = regexp.MustCompile(`^([A-Z_]+)\s+(\d+)(?:\s|$)`)
+
+func NewTpstats(d []byte) *Tpstats {
+	t := &Tpstats{Pools: make([]*ThreadPool, 0), Dropped: make(map[string]int64)}
+	dropped := false
+	for _, line := range strings.Split(string(d), "\n") {
+		if strings.HasPrefix(line, "Message type") {
+			dropped = true
+		} else if dropped {
+			if parts := tpstatsDroppedRegex.FindStringSubmatch(line); parts != nil {
+				t.Dropped[parts[1]], _ = strconv.ParseInt(parts[2], 10, 64)
+			}
+		} else if parts := tpstatsPoolRegex.FindStringSubmatch(line); parts != nil {
+			pool := &ThreadPool{Name: parts[1]}
+			pool.Active, _ = strconv.ParseInt(parts[2], 10, 64)
+			pool.Pending, _ = strconv.ParseInt(parts[3], 10, 64)
+			pool.Completed, _ = strconv.ParseInt(parts[4], 10, 64)
+			pool.Blocked, _ = strconv.ParseInt(parts[5], 10, 64)
+			pool.AllTimeBlocked, _ = strconv.ParseInt(parts[6], 10, 64)
+			t.Pools = append(t.Pools, pool)
+		}
+	}
+	return t
+}
+
+// Pool returns the thread pool called name or nil.
+func (t *Tpstats) Pool(name string) *ThreadPool {
+	for _, pool := range t.Pools {
+		if pool.Name == name {
+			return pool
+		}
+	}
+	return nil
+}
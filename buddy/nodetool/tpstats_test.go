@@ -0,0 +1,39 @@
+package nodetool
+
+import "testing"
+
+var testTpstats = []byte(`Pool Name                    Active   Pending      Completed   Blocked  All time blocked
+MutationStage                     0         0        1285391         0                 0
+ViewMutationStage                 0         0              0         0                 0
+ReadStage                         2         5         734106         0                 0
+RequestResponseStage              0         0        1830279         0                 0
+CompactionExecutor                2        14          93208         0                 0
+MemtableFlushWriter               1         2           1042         0                 0
+Native-Transport-Requests         1         0        2197463         0                 9
+
+Message type           Dropped
+READ                         0
+RANGE_SLICE                  0
+MUTATION                    12
+HINT                         0
+`)
+
+func TestNewTpstats(t *testing.T) {
+	stats := NewTpstats(testTpstats)
+	if len(stats.Pools) != 7 {
+		t.Fatalf("expected 7 pools, got %d", len(stats.Pools))
+	}
+	want := ThreadPool{Name: "CompactionExecutor", Active: 2, Pending: 14, Completed: 93208}
+	if pool := stats.Pool("CompactionExecutor"); pool == nil || *pool != want {
+		t.Fatalf("unexpected pool %+v", pool)
+	}
+	if pool := stats.Pool("Native-Transport-Requests"); pool.AllTimeBlocked != 9 {
+		t.Fatalf("unexpected pool %+v", pool)
+	}
+	if len(stats.Dropped) != 4 || stats.Dropped["MUTATION"] != 12 {
+		t.Fatalf("unexpected dropped %v", stats.Dropped)
+	}
+	if stats.Pool("missing") != nil {
+		t.Fatal("expected no pool")
+	}
+}
@@ -0,0 +1,137 @@
+package buddy
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Nomon/cassandra-buddy/buddy/structs"
+)
+
+// Operation kinds
+const (
+	operationBackup  = "backup"
+	operationRestore = "restore"
+)
+
+// operations tracks the backups and restores in progress so cassandra is not
+// stopped under them.
+type operations struct {
+	sync.Mutex
+	running map[*structs.Operation]bool
+	// stopping is set while cassandra is stopped, see stop.
+	stopping bool
+}
+
+// begin records an operation, end removes it. Operations do not begin while
+// cassandra is being stopped.
+func (o *operations) begin(kind, name string) (*structs.Operation, error) {
+	o.Lock()
+	defer o.Unlock()
+	if o.stopping {
+		return nil, errors.New("Cassandra is being stopped")
+	}
+	op := &structs.Operation{Kind: kind, Name: name, StartedAt: time.Now()}
+	if o.running == nil {
+		o.running = make(map[*structs.Operation]bool)
+	}
+	o.running[op] = true
+	return op, nil
+}
+
+func (o *operations) end(op *structs.Operation) {
+	o.Lock()
+	defer o.Unlock()
+	delete(o.running, op)
+}
+
+func (o *operations) list() []*structs.Operation {
+	o.Lock()
+	defer o.Unlock()
+	return o.sorted()
+}
+
+func (o *operations) sorted() []*structs.Operation {
+	ops := make([]*structs.Operation, 0, len(o.running))
+	for op := range o.running {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].StartedAt.Before(ops[j].StartedAt) })
+	return ops
+}
+
+// idle returns an error naming the operations in progress other than self,
+// unless forced.
+func (o *operations) idle(force bool, self *structs.Operation) error {
+	o.Lock()
+	defer o.Unlock()
+	return o.idleLocked(force, self)
+}
+
+func (o *operations) idleLocked(force bool, self *structs.Operation) error {
+	if force {
+		return nil
+	}
+	msg := ""
+	for _, op := range o.sorted() {
+		if op == self {
+			continue
+		}
+		if msg != "" {
+			msg += ","
+		}
+		msg += fmt.Sprintf(" %s %s", op.Kind, op.Name)
+	}
+	if msg == "" {
+		return nil
+	}
+	return errors.New("Cassandra is in use by" + msg)
+}
+
+// start returns an error naming the restores in progress, unless forced. A
+// restore starts cassandra itself once the data is in place.
+func (o *operations) start(force bool) error {
+	if force {
+		return nil
+	}
+	o.Lock()
+	defer o.Unlock()
+	msg := ""
+	for _, op := range o.sorted() {
+		if op.Kind != operationRestore {
+			continue
+		}
+		if msg != "" {
+			msg += ","
+		}
+		msg += fmt.Sprintf(" %s %s", op.Kind, op.Name)
+	}
+	if msg == "" {
+		return nil
+	}
+	return errors.New("Cassandra is being restored by" + msg)
+}
+
+// stop runs stop when no operations other than self are in progress, unless
+// forced. The check and stop are not interleaved with operations beginning.
+func (o *operations) stop(force bool, self *structs.Operation, stop func() error) error {
+	o.Lock()
+	if err := o.idleLocked(force, self); err != nil {
+		o.Unlock()
+		return err
+	}
+	if o.stopping {
+		o.Unlock()
+		return errors.New("Cassandra is being stopped")
+	}
+	o.stopping = true
+	o.Unlock()
+	defer func() {
+		o.Lock()
+		o.stopping = false
+		o.Unlock()
+	}()
+	return stop()
+}
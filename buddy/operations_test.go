@@ -0,0 +1,79 @@
+package buddy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOperations(t *testing.T) {
+	var ops operations
+	if err := ops.idle(false, nil); err != nil {
+		t.Fatal(err)
+	}
+	op, err := ops.begin(operationBackup, "1460628403086")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ops.idle(false, nil)
+	if err == nil || !strings.Contains(err.Error(), "backup 1460628403086") {
+		t.Fatalf("expected the running backup to block, got %v", err)
+	}
+	if err := ops.idle(true, nil); err != nil {
+		t.Fatal("force did not override the running backup")
+	}
+	if err := ops.idle(false, op); err != nil {
+		t.Fatal("operation blocked itself")
+	}
+	ops.end(op)
+	if len(ops.list()) != 0 {
+		t.Fatal("ended operation is still listed")
+	}
+}
+
+func TestOperationsStart(t *testing.T) {
+	var ops operations
+	backup, _ := ops.begin(operationBackup, "1460628403086")
+	if err := ops.start(false); err != nil {
+		t.Fatal("a running backup blocked the start")
+	}
+	restore, _ := ops.begin(operationRestore, "s3://bucket/manifest")
+	err := ops.start(false)
+	if err == nil || !strings.Contains(err.Error(), "restore s3://bucket/manifest") {
+		t.Fatalf("expected the running restore to block, got %v", err)
+	}
+	if err := ops.start(true); err != nil {
+		t.Fatal("force did not override the running restore")
+	}
+	ops.end(restore)
+	ops.end(backup)
+}
+
+func TestOperationsStop(t *testing.T) {
+	var ops operations
+	op, _ := ops.begin(operationRestore, "s3://bucket/manifest")
+	stopped := false
+	stop := func() error {
+		stopped = true
+		if _, err := ops.begin(operationBackup, "1460628403086"); err == nil {
+			t.Error("backup began while cassandra was stopped")
+		}
+		return nil
+	}
+	if err := ops.stop(false, nil, stop); err == nil || stopped {
+		t.Fatal("cassandra was stopped under the running restore")
+	}
+	if err := ops.stop(false, op, stop); err != nil || !stopped {
+		t.Fatalf("restore could not stop cassandra: %v", err)
+	}
+	ops.end(op)
+	failed := errors.New("stop failed")
+	if err := ops.stop(false, nil, func() error { return failed }); err != failed {
+		t.Fatalf("expected the stop error, got %v", err)
+	}
+	if op, err := ops.begin(operationBackup, "1460628403086"); err != nil {
+		t.Fatal("operations do not begin after a stop")
+	} else {
+		ops.end(op)
+	}
+}
@@ -0,0 +1,71 @@
+package buddy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Nomon/cassandra-buddy/buddy/cassandra"
+)
+
+// checkVolume fails when required bytes plus DiskSpaceMargin do not fit in the
+// space available on v and the bytes freed before the operation.
+func (srv *Server) checkVolume(v *cassandra.Volume, op string, required, freed int64) error {
+	need := int64(float64(required) * (1 + srv.cfg.DiskSpaceMargin))
+	if available := v.Available + freed; available < need {
+		return fmt.Errorf("Not enough disk space for %s on %s: %d bytes needed including a %.0f%% margin, %d available",
+			op, strings.Join(v.Paths, ", "), need, srv.cfg.DiskSpaceMargin*100, available)
+	}
+	return nil
+}
+
+// preflightSnapshot checks that every data volume could hold its live sstables
+// again, a snapshot keeps sstables compacted after it on disk.
+func (srv *Server) preflightSnapshot() error {
+	volumes, err := cassandra.Volumes(srv.casConfig().DataPaths)
+	if err != nil {
+		return err
+	}
+	for _, v := range volumes {
+		var live int64
+		for _, path := range v.Paths {
+			size, err := cassandra.DataSize(path, nil, false)
+			if err != nil {
+				return err
+			}
+			live += size
+		}
+		if err := srv.checkVolume(v, "snapshot", live, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// preflightRestore checks that the snapshot at path fits on the data volumes
+// once keyspaces are cleared. Restored sstables are spread evenly over the data
+// directories so each volume needs its share.
+func (srv *Server) preflightRestore(path string, keyspaces []string) error {
+	manifest, err := srv.store.Manifest(path)
+	if err != nil {
+		return err
+	}
+	volumes, err := cassandra.Volumes(srv.casConfig().DataPaths)
+	if err != nil {
+		return err
+	}
+	for _, v := range volumes {
+		share := manifest.Size * int64(len(v.Paths)) / int64(len(srv.casConfig().DataPaths))
+		var freed int64
+		for _, p := range v.Paths {
+			size, err := cassandra.DataSize(p, keyspaces, true)
+			if err != nil {
+				return err
+			}
+			freed += size
+		}
+		if err := srv.checkVolume(v, "restore of "+manifest.Name, share, freed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
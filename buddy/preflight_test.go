@@ -0,0 +1,61 @@
+package buddy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Nomon/cassandra-buddy/buddy/cassandra"
+	"github.com/Nomon/cassandra-buddy/datastore"
+)
+
+type manifestStore struct {
+	datastore.Store
+	manifest *datastore.Manifest
+}
+
+func (s *manifestStore) Manifest(path string) (*datastore.Manifest, error) {
+	return s.manifest, nil
+}
+
+func TestPreflightRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buddy-preflight")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	table := filepath.Join(dir, "app", "events-5b1a7e20f6a611e5a8a4c1d3a4c7e9a1")
+	if err := os.MkdirAll(table, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(table, "mc-1-big-Data.db"), make([]byte, 1<<20), 0644); err != nil {
+		t.Fatal(err)
+	}
+	volumes, err := cassandra.Volumes([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	available := volumes[0].Available
+
+	store := &manifestStore{manifest: &datastore.Manifest{Name: "20160415120000"}}
+	cascfg := cassandra.DefaultConfig()
+	cascfg.DataPaths = []string{dir}
+	srv := &Server{cfg: NewConfig(), cascfg: cascfg, store: store}
+
+	// the cleared sstable makes room for a snapshot slightly larger than the
+	// free space
+	store.manifest.Size = int64(float64(available+1<<19) / 1.1)
+	if err := srv.preflightRestore("/manifest.json", nil); err != nil {
+		t.Fatal(err)
+	}
+	store.manifest.Size = available + 1<<21
+	err = srv.preflightRestore("/manifest.json", nil)
+	if err == nil || !strings.Contains(err.Error(), "Not enough disk space for restore of 20160415120000") {
+		t.Fatalf("expected not enough space, got %v", err)
+	}
+	if err := srv.preflightSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+}
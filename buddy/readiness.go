@@ -0,0 +1,179 @@
+package buddy
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Nomon/cassandra-buddy/buddy/cassandra"
+	"github.com/Nomon/cassandra-buddy/buddy/nodetool"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// Readiness phases a starting node goes through.
+const (
+	phaseNodetool        = "waiting for nodetool"
+	phaseGossip          = "waiting for gossip"
+	phaseNativeTransport = "waiting for native transport"
+	phaseRing            = "waiting to be up and normal"
+	phaseCluster         = "waiting for cluster info"
+	phaseReady           = "ready"
+)
+
+// readinessChecker waits for a started node to join the ring and serve clients.
+type readinessChecker struct {
+	nt  nodetool.Nodetool
+	cas cassandra.Process
+	// joinRing is false for a node started with join_ring=false, it never
+	// becomes up and normal so the ring phase is skipped.
+	joinRing bool
+	log      log15.Logger
+	interval time.Duration
+	// logLines is how many lines of cassandra output a failure includes
+	logLines int
+
+	mu    sync.Mutex
+	phase string
+	// reason is why the last check did not pass
+	reason string
+}
+
+func newReadinessChecker(nt nodetool.Nodetool, cas cassandra.Process, joinRing bool, logger log15.Logger) *readinessChecker {
+	return &readinessChecker{
+		nt:       nt,
+		cas:      cas,
+		joinRing: joinRing,
+		log:      logger,
+		interval: 1 * time.Second,
+		logLines: 20,
+		phase:    phaseNodetool,
+	}
+}
+
+// Phase returns the phase the node is in.
+func (r *readinessChecker) Phase() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.phase
+}
+
+// Wait polls the node until it is ready or timeout passes, a process that
+// exited and is not restarted fails right away. The error of a node that does
+// not become ready names the phase it got stuck in and the last lines cassandra
+// wrote.
+func (r *readinessChecker) Wait(timeout time.Duration) (*nodetool.Info, *nodetool.ClusterInfo, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		info, cluster, ok := r.check()
+		if ok {
+			return info, cluster, nil
+		}
+		if err := r.exitError(); err != nil {
+			return nil, nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, nil, r.timeoutError(timeout)
+		}
+		time.Sleep(r.interval)
+	}
+}
+
+func (r *readinessChecker) check() (*nodetool.Info, *nodetool.ClusterInfo, bool) {
+	info, err := r.nt.Info()
+	if err != nil || info.ID == "" {
+		r.setPhase(phaseNodetool, err)
+		return nil, nil, false
+	}
+	if !info.GossipActive {
+		r.setPhase(phaseGossip, nil)
+		return nil, nil, false
+	}
+	if !info.NativeTransportActive {
+		r.setPhase(phaseNativeTransport, nil)
+		return nil, nil, false
+	}
+	if r.joinRing {
+		status, err := r.nt.Status()
+		if err != nil {
+			r.setPhase(phaseRing, err)
+			return nil, nil, false
+		}
+		if state := nodeState(status, info.ID); state != "UN" {
+			r.setPhase(phaseRing, fmt.Errorf("node state is %q", state))
+			return nil, nil, false
+		}
+	}
+	cluster, err := r.nt.ClusterInfo()
+	if err != nil || cluster.Name == "" {
+		r.setPhase(phaseCluster, err)
+		return nil, nil, false
+	}
+	r.setPhase(phaseReady, nil)
+	return info, cluster, true
+}
+
+func (r *readinessChecker) setPhase(phase string, reason error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if phase != r.phase {
+		r.log.Info("Cassandra readiness", "phase", phase)
+	}
+	r.phase = phase
+	r.reason = ""
+	if reason != nil {
+		r.reason = reason.Error()
+	}
+}
+
+// exitError returns an error when the process exited and the supervisor gave
+// up restarting it.
+func (r *readinessChecker) exitError() error {
+	if r.cas == nil {
+		return nil
+	}
+	state := r.cas.State()
+	if !state.GaveUp {
+		return nil
+	}
+	reason := "exited"
+	if state.LastExit != nil {
+		reason = state.LastExit.Reason
+	}
+	return r.notReadyError(fmt.Sprintf("Cassandra %s and is not restarted", reason))
+}
+
+func (r *readinessChecker) timeoutError(timeout time.Duration) error {
+	return r.notReadyError(fmt.Sprintf("Cassandra not ready after %s", timeout))
+}
+
+// notReadyError adds the phase, the reason and the last lines of output to msg.
+func (r *readinessChecker) notReadyError(msg string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msg = fmt.Sprintf("%s, %s", msg, r.phase)
+	if r.reason != "" {
+		msg += ": " + r.reason
+	}
+	if r.cas != nil && !r.cas.Running() {
+		msg += ", cassandra is not running"
+	}
+	if r.cas != nil && r.cas.Output() != nil {
+		if lines := r.cas.Output().Tail(r.logLines); len(lines) > 0 {
+			msg += "\n" + strings.Join(lines, "\n")
+		}
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// nodeState returns the status of the node with hostID, like UN or DJ.
+func nodeState(status *nodetool.Status, hostID string) string {
+	for _, dc := range status.Datacenters {
+		for _, node := range dc.Nodes {
+			if node.HostID == hostID {
+				return node.State
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package buddy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nomon/cassandra-buddy/buddy/cassandra"
+	"github.com/Nomon/cassandra-buddy/buddy/nodetool"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// fakeNodetool answers with canned parsed output.
+type fakeNodetool struct {
+	nodetool.Nodetool
+	info    *nodetool.Info
+	status  *nodetool.Status
+	cluster *nodetool.ClusterInfo
+}
+
+func (f *fakeNodetool) Info() (*nodetool.Info, error) {
+	if f.info == nil {
+		return nil, errors.New("Connection refused")
+	}
+	return f.info, nil
+}
+
+func (f *fakeNodetool) Status() (*nodetool.Status, error) {
+	return f.status, nil
+}
+
+func (f *fakeNodetool) ClusterInfo() (*nodetool.ClusterInfo, error) {
+	return f.cluster, nil
+}
+
+const testHostID = "5b1a7e20-f6a6-11e5-a8a4-c1d3a4c7e9a1"
+
+func testStatus(state string) *nodetool.Status {
+	return &nodetool.Status{Datacenters: []nodetool.Datacenter{{
+		Name:  "us-west",
+		Nodes: []nodetool.Node{{State: state, Address: "10.0.0.1", HostID: testHostID}},
+	}}}
+}
+
+func TestReadinessPhases(t *testing.T) {
+	nt := &fakeNodetool{}
+	r := newReadinessChecker(nt, nil, true, log15.New())
+
+	steps := []struct {
+		apply func()
+		phase string
+	}{
+		{func() {}, phaseNodetool},
+		{func() { nt.info = &nodetool.Info{ID: testHostID} }, phaseGossip},
+		{func() { nt.info.GossipActive = true }, phaseNativeTransport},
+		{func() { nt.info.NativeTransportActive = true; nt.status = testStatus("UJ") }, phaseRing},
+		{func() { nt.status = testStatus("UN"); nt.cluster = &nodetool.ClusterInfo{} }, phaseCluster},
+		{func() { nt.cluster.Name = "challenge-cassandra" }, phaseReady},
+	}
+	for _, step := range steps {
+		step.apply()
+		_, _, ok := r.check()
+		if r.Phase() != step.phase {
+			t.Fatalf("expected phase %q, got %q", step.phase, r.Phase())
+		}
+		if ok != (step.phase == phaseReady) {
+			t.Fatalf("unexpected readiness %v in phase %q", ok, step.phase)
+		}
+	}
+}
+
+func TestReadinessWithoutRing(t *testing.T) {
+	nt := &fakeNodetool{
+		info:    &nodetool.Info{ID: testHostID, GossipActive: true, NativeTransportActive: true},
+		status:  testStatus("DN"),
+		cluster: &nodetool.ClusterInfo{Name: "challenge-cassandra"},
+	}
+	r := newReadinessChecker(nt, nil, false, log15.New())
+	if _, _, ok := r.check(); !ok || r.Phase() != phaseReady {
+		t.Fatalf("node outside the ring is not ready, phase %q", r.Phase())
+	}
+}
+
+func TestReadinessTimeout(t *testing.T) {
+	nt := &fakeNodetool{info: &nodetool.Info{ID: testHostID, GossipActive: true, NativeTransportActive: true}, status: testStatus("DN")}
+	r := newReadinessChecker(nt, nil, true, log15.New())
+	r.interval = time.Millisecond
+	_, _, err := r.Wait(10 * time.Millisecond)
+	if err == nil {
+		t.Fatal("expected a timeout")
+	}
+	if !strings.Contains(err.Error(), phaseRing) || !strings.Contains(err.Error(), `"DN"`) {
+		t.Fatalf("error does not explain the phase: %v", err)
+	}
+}
+
+// exitedProcess is a cassandra the supervisor gave up on.
+type exitedProcess struct {
+	cassandra.Process
+}
+
+func (p *exitedProcess) Running() bool { return false }
+
+func (p *exitedProcess) Output() *cassandra.Output { return nil }
+
+func (p *exitedProcess) State() *cassandra.ProcessState {
+	return &cassandra.ProcessState{GaveUp: true, LastExit: &cassandra.Exit{Code: 1, Reason: "exited with status 1"}}
+}
+
+func TestReadinessExited(t *testing.T) {
+	r := newReadinessChecker(&fakeNodetool{}, &exitedProcess{}, true, log15.New())
+	start := time.Now()
+	_, _, err := r.Wait(time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "exited with status 1") {
+		t.Fatalf("expected an exit error, got %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Fatal("Wait kept polling an exited process")
+	}
+}
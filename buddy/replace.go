@@ -0,0 +1,138 @@
+package buddy
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Nomon/cassandra-buddy/buddy/cassandra"
+	"github.com/Nomon/cassandra-buddy/buddy/structs"
+)
+
+// Replacement states
+const (
+	replacementBootstrapping = "bootstrapping"
+	replacementDone          = "done"
+	replacementFailed        = "failed"
+)
+
+// replacements tracks the node replacement started on this node.
+type replacements struct {
+	sync.Mutex
+	current *structs.Replacement
+}
+
+// replace restarts cassandra as a replacement for the dead node at addr. The
+// bootstrap is monitored in the background, see Replacement for its progress.
+// Cassandra ignores the replace address of a node with local state, clearData
+// removes it, otherwise such a node is refused. Cassandra is not stopped under
+// backups or restores unless forced.
+func (srv *Server) replace(args *structs.CassandraReplaceRequest) (*structs.Replacement, error) {
+	addr := args.Address
+	if !args.ClearData {
+		hasState, err := cassandra.HasState(srv.casConfig())
+		if err != nil {
+			return nil, err
+		}
+		if hasState {
+			return nil, errors.New("Node has data, commit logs or saved caches, set ClearData to remove them before the replacement")
+		}
+	}
+	srv.replacements.Lock()
+	if r := srv.replacements.current; r != nil && r.State == replacementBootstrapping {
+		srv.replacements.Unlock()
+		return nil, errors.New("A node replacement is already in progress")
+	}
+	srv.replacements.current = &structs.Replacement{
+		Address:   addr,
+		State:     replacementBootstrapping,
+		StartedAt: time.Now(),
+	}
+	srv.replacements.Unlock()
+
+	err := srv.operations.stop(args.Force, nil, srv.stopCassandra)
+	if err == nil && args.ClearData {
+		err = srv.process().ClearData(nil)
+	}
+	if err == nil && args.ClearData {
+		err = srv.process().ClearLogs()
+	}
+	if err == nil {
+		err = srv.process().SetReplaceAddress(addr)
+	}
+	if err == nil {
+		err = srv.process().Start()
+	}
+	if err != nil {
+		srv.finishReplacement(err)
+		return nil, err
+	}
+	go srv.monitorReplacement()
+	return srv.replacement(), nil
+}
+
+// replacement returns a copy of the current replacement, nil when none was started.
+func (srv *Server) replacement() *structs.Replacement {
+	srv.replacements.Lock()
+	defer srv.replacements.Unlock()
+	if srv.replacements.current == nil {
+		return nil
+	}
+	r := *srv.replacements.current
+	return &r
+}
+
+func (srv *Server) finishReplacement(err error) {
+	srv.replacements.Lock()
+	defer srv.replacements.Unlock()
+	r := srv.replacements.current
+	r.FinishedAt = time.Now()
+	if err != nil {
+		r.State = replacementFailed
+		r.Error = err.Error()
+		srv.log.Error("Node replacement failed", "address", r.Address, "error", err)
+		return
+	}
+	r.State = replacementDone
+	srv.log.Info("Node replacement done", "address", r.Address, "took", r.FinishedAt.Sub(r.StartedAt))
+}
+
+// monitorReplacement follows bootstrap streaming until the node is up and
+// normal, then clears the replace address so later starts join normally.
+func (srv *Server) monitorReplacement() {
+	// a replacement is only done once it is up and normal in the ring
+	readiness := newReadinessChecker(srv.nodetool(), srv.process(), true, srv.log)
+	srv.setReadiness(readiness)
+	deadline := time.Now().Add(srv.cfg.ReplaceTimeout)
+	for {
+		time.Sleep(10 * time.Second)
+		if netstats, err := srv.nodetool().Netstats(); err == nil {
+			received, total := netstats.Received()
+			srv.replacements.Lock()
+			r := srv.replacements.current
+			r.Mode = netstats.Mode
+			// streams are gone from netstats once bootstrap finishes
+			if total > 0 {
+				r.Received, r.Total = received, total
+			}
+			srv.replacements.Unlock()
+			srv.log.Debug("Replacement progress", "mode", netstats.Mode, "received", received, "total", total)
+		}
+		if info, cluster, ok := readiness.check(); ok {
+			srv.setNode(info, cluster)
+			if err := srv.process().SetReplaceAddress(""); err != nil {
+				srv.log.Error("Failed to clear replace address", "error", err)
+			}
+			srv.finishReplacement(nil)
+			return
+		}
+		if err := readiness.exitError(); err != nil {
+			srv.finishReplacement(err)
+			return
+		}
+		if time.Now().After(deadline) {
+			srv.finishReplacement(readiness.timeoutError(srv.cfg.ReplaceTimeout))
+			return
+		}
+	}
+}
@@ -1,16 +1,20 @@
 package buddy
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/rpc"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/Nomon/cassandra-buddy/buddy/cassandra"
+	"github.com/Nomon/cassandra-buddy/buddy/cqlsh"
 	"github.com/Nomon/cassandra-buddy/buddy/nodetool"
 	"github.com/Nomon/cassandra-buddy/buddy/structs"
 	"github.com/Nomon/cassandra-buddy/datastore"
@@ -22,11 +26,23 @@ import (
 type Server struct {
 	cfg *Config
 	log log15.Logger
-	// Cassandra process
-	cascfg     *cassandra.Config
-	cas        cassandra.Process
+	// Cassandra process, replaced by restores and guarded by nodeMu, see process
+	cascfg *cassandra.Config
+	cas    cassandra.Process
+	nt     nodetool.Nodetool
+	// readiness and what is known about the node are replaced by background
+	// readiness checks, they are guarded by nodeMu, see node
+	nodeMu     sync.Mutex
 	casinfo    *nodetool.Info
 	cascluster *nodetool.ClusterInfo
+	readiness  *readinessChecker
+	cql        *cqlsh.Cql
+	// node replacement, see replace
+	replacements replacements
+	// backups and restores in progress
+	operations operations
+	// statistics served to health checks
+	healthCache healthCache
 	// http
 	mux *echo.Echo
 	// rpc
@@ -38,6 +54,7 @@ type Server struct {
 
 type endpoints struct {
 	Snapshots *Snapshots
+	Cassandra *Cassandra
 }
 
 func NewServer(cfg *Config) *Server {
@@ -65,6 +82,11 @@ func NewServer(cfg *Config) *Server {
 		srv.log.Error("Failed to start cassandra", "error", err)
 		panic(err)
 	}
+	// the native port is read from cassandra.yaml
+	if err := srv.setupCql(); err != nil {
+		srv.log.Error("Failed to setup cql", "error", err)
+		panic(err)
+	}
 	if err := srv.setupStore(); err != nil {
 		srv.log.Error("Failed to setup store", "error", err)
 		panic(err)
@@ -82,7 +104,7 @@ func (srv *Server) Serve() {
 }
 
 func (srv *Server) Close() error {
-	return nil
+	return srv.cql.Close()
 }
 
 // RPC is used to make a local RPC call
@@ -106,9 +128,20 @@ func (srv *Server) RPC(method string, args interface{}, reply interface{}) error
 	return codec.err
 }
 
+func (srv *Server) setupCql() error {
+	cqlcfg, err := srv.cfg.CqlConfig(srv.casConfig().NativePort)
+	if err != nil {
+		return err
+	}
+	srv.cql = cqlsh.NeqCql(cqlcfg)
+	return nil
+}
+
 func (srv *Server) setupRPC() error {
 	srv.endpoints.Snapshots = &Snapshots{srv}
 	srv.rpcServer.Register(srv.endpoints.Snapshots)
+	srv.endpoints.Cassandra = &Cassandra{srv}
+	srv.rpcServer.Register(srv.endpoints.Cassandra)
 	return nil
 }
 
@@ -116,48 +149,199 @@ func (srv *Server) setupHTTP() error {
 	srv.mux = echo.New()
 	srv.mux.Post("/snapshots/create", srv.CreateSnapshot)
 	srv.mux.Post("/snapshots/restore", srv.RestoreSnapshot)
+	srv.mux.Get("/snapshots/:name/schema", srv.SnapshotSchema)
+	srv.mux.Get("/snapshots/:name/schema/diff", srv.SnapshotSchemaDiff)
+	srv.mux.Get("/local-snapshots", srv.LocalSnapshots)
+	srv.mux.Post("/local-snapshots/purge", srv.PurgeLocalSnapshots)
+	srv.mux.Get("/cassandra/process", srv.CassandraProcess)
+	srv.mux.Get("/cassandra/logs", srv.CassandraLogs)
+	srv.mux.Post("/cassandra/start", srv.CassandraStart)
+	srv.mux.Post("/cassandra/stop", srv.CassandraStop)
+	srv.mux.Post("/cassandra/restart", srv.CassandraRestart)
+	srv.mux.Get("/cassandra/status", srv.CassandraStatus)
+	srv.mux.Get("/cassandra/health", srv.CassandraHealth)
+	srv.mux.Post("/cassandra/replace", srv.CassandraReplace)
+	srv.mux.Get("/cassandra/replace", srv.CassandraReplacement)
 	return nil
 }
 
 func (srv *Server) setupCassandra() error {
-	srv.cascfg = cassandra.DefaultConfig()
-	srv.cas = cassandra.New(srv.cascfg)
-	err := srv.cas.Start()
+	cascfg, problems, err := srv.cfg.CassandraConfig()
 	if err != nil {
 		return err
 	}
-	nt := nodetool.New()
-	for {
-		time.Sleep(1 * time.Second)
-		info, err := nt.Info()
-		if err != nil || info.ID == "" {
-			continue
-		}
+	for _, problem := range problems {
+		srv.log.Warn("Cassandra configuration is incompatible with backups", "problem", problem)
+	}
+	nt := nodetool.New(srv.cfg.NodetoolConfig(cascfg.JmxPort))
+	var cas cassandra.Process
+	if srv.cfg.CassandraAttach {
+		cas = cassandra.Attach(cascfg)
+	} else {
+		cas = cassandra.New(cascfg)
+	}
+	// followers of the replaced process would wait for output forever
+	if old := srv.process(); old != nil && old.Output() != nil {
+		old.Output().CloseFollowers()
+	}
+	srv.setProcess(cascfg, cas, nt)
+	if err := srv.startCassandra(true); err != nil {
+		return err
+	}
+	_, info, cluster := srv.node()
+	srv.log.Info("Cassandra started", "info", info, "cluster", cluster)
+	return nil
+}
+
+// process returns the cassandra process set up last, see setupCassandra.
+func (srv *Server) process() cassandra.Process {
+	srv.nodeMu.Lock()
+	defer srv.nodeMu.Unlock()
+	return srv.cas
+}
+
+// casConfig returns the configuration of the cassandra process.
+func (srv *Server) casConfig() *cassandra.Config {
+	srv.nodeMu.Lock()
+	defer srv.nodeMu.Unlock()
+	return srv.cascfg
+}
+
+// nodetool returns the nodetool of the cassandra process.
+func (srv *Server) nodetool() nodetool.Nodetool {
+	srv.nodeMu.Lock()
+	defer srv.nodeMu.Unlock()
+	return srv.nt
+}
+
+func (srv *Server) setProcess(cascfg *cassandra.Config, cas cassandra.Process, nt nodetool.Nodetool) {
+	srv.nodeMu.Lock()
+	defer srv.nodeMu.Unlock()
+	srv.cascfg = cascfg
+	srv.cas = cas
+	srv.nt = nt
+}
+
+// node returns the readiness checker, the node info and the cluster info, the
+// infos are nil until the node was ready once.
+func (srv *Server) node() (*readinessChecker, *nodetool.Info, *nodetool.ClusterInfo) {
+	srv.nodeMu.Lock()
+	defer srv.nodeMu.Unlock()
+	return srv.readiness, srv.casinfo, srv.cascluster
+}
+
+func (srv *Server) setReadiness(readiness *readinessChecker) {
+	srv.nodeMu.Lock()
+	defer srv.nodeMu.Unlock()
+	srv.readiness = readiness
+}
+
+// setNode records the infos of a ready node, a nil info is left alone.
+func (srv *Server) setNode(info *nodetool.Info, cluster *nodetool.ClusterInfo) {
+	srv.nodeMu.Lock()
+	defer srv.nodeMu.Unlock()
+	if info != nil {
 		srv.casinfo = info
-		break
 	}
+	if cluster != nil {
+		srv.cascluster = cluster
+	}
+}
+
+// readyNode returns the node and cluster info or an error when the node was
+// never ready.
+func (srv *Server) readyNode() (*nodetool.Info, *nodetool.ClusterInfo, error) {
+	_, info, cluster := srv.node()
+	if info == nil || cluster == nil {
+		return nil, nil, errors.New("Cassandra has not been ready yet")
+	}
+	return info, cluster, nil
+}
+
+// waitForSchemaAgreement polls describecluster until all reachable nodes report
+// the same schema version.
+func (srv *Server) waitForSchemaAgreement(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
 	for {
+		info, err := srv.nodetool().ClusterInfo()
+		if err == nil && info.SchemaAgreement() {
+			srv.setNode(nil, info)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("No schema agreement after %s: %v", timeout, err)
+			}
+			return fmt.Errorf("No schema agreement after %s: %v", timeout, info.SchemaVersions)
+		}
 		time.Sleep(1 * time.Second)
-		info, err := nt.ClusterInfo()
-		if err != nil || info.Name == "" {
-			continue
+	}
+}
+
+// startCassandra starts cassandra, with block it returns once the node is up
+// and normal.
+func (srv *Server) startCassandra(block bool) error {
+	if err := srv.process().Start(); err != nil {
+		return err
+	}
+	readiness := newReadinessChecker(srv.nodetool(), srv.process(), srv.casConfig().JoinRing, srv.log)
+	srv.setReadiness(readiness)
+	wait := func() error {
+		info, cluster, err := readiness.Wait(srv.cfg.StartTimeout)
+		if err != nil {
+			return err
 		}
-		srv.cascluster = info
-		break
+		srv.setNode(info, cluster)
+		return nil
 	}
-	srv.log.Info("Cassandra started", "info", srv.casinfo, "cluster", srv.cascluster)
-	return nil
+	if !block {
+		go func() {
+			if err := wait(); err != nil {
+				srv.log.Error("Cassandra did not become ready", "error", err)
+			}
+		}()
+		return nil
+	}
+	return wait()
+}
+
+// stopCassandra drains the node so the commit log does not need a replay and
+// stops it, a drain that fails or takes longer than DrainTimeout does not
+// keep the node from stopping.
+func (srv *Server) stopCassandra() error {
+	if !srv.process().Running() {
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.nodetool().Drain()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			srv.log.Warn("Drain failed, stopping cassandra anyway", "error", err)
+		} else {
+			srv.log.Info("Cassandra drained")
+		}
+	case <-time.After(srv.cfg.DrainTimeout):
+		srv.log.Warn("Drain did not finish, stopping cassandra anyway", "timeout", srv.cfg.DrainTimeout)
+	}
+	return srv.process().Stop()
 }
 
 func (srv *Server) setupStore() error {
 	//srv.store = datastore.NewFs("/Users/nomon/casb")
-	clusterName := strings.Replace(srv.cascluster.Name, " ", "_-_", -1)
-	basePath := filepath.Join(srv.cfg.S3Path, clusterName, srv.casinfo.ID)
+	info, cluster, err := srv.readyNode()
+	if err != nil {
+		return err
+	}
+	clusterName := strings.Replace(cluster.Name, " ", "_-_", -1)
+	basePath := filepath.Join(srv.cfg.S3Path, clusterName, info.ID)
 	srv.store = datastore.NewS3(&datastore.S3Cfg{
-		DataPath: srv.cascfg.DataPath,
-		BasePath: basePath,
-		Region:   srv.cfg.S3Region,
-		Bucket:   srv.cfg.S3Bucket,
+		DataPaths: srv.casConfig().DataPaths,
+		BasePath:  basePath,
+		Region:    srv.cfg.S3Region,
+		Bucket:    srv.cfg.S3Bucket,
 	})
 	return nil
 }
@@ -168,7 +352,12 @@ func (srv *Server) setupSignals() error {
 	go func() {
 		select {
 		case <-ch:
-			srv.cas.Stop()
+			// an attached cassandra outlives buddy
+			if !srv.cfg.CassandraAttach {
+				if err := srv.stopCassandra(); err != nil {
+					srv.log.Error("Failed to stop cassandra", "error", err)
+				}
+			}
 			os.Exit(0)
 		}
 	}()
@@ -1,12 +1,15 @@
 package buddy
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/Nomon/cassandra-buddy/buddy/cqlsh"
 	"github.com/Nomon/cassandra-buddy/buddy/nodetool"
 	"github.com/Nomon/cassandra-buddy/buddy/structs"
 	"github.com/Nomon/cassandra-buddy/datastore"
@@ -22,31 +25,132 @@ func (s *Snapshots) Create(args *structs.SnapshotsCreateRequest, reply *structs.
 	if args.Name == "" {
 		args.Name = createManifestName()
 	}
-	log.Println("Creating snapshot", "path", s.srv.cascfg.BackupPath+"/"+args.Name)
-	nt := nodetool.New()
-	snapshot, err := nt.Snapshot(args.Name, nil, nil)
-	log.Println(snapshot, err)
+	// a postponed backup is not registered, it does not keep cassandra from
+	// being stopped while it waits
+	if !args.Force {
+		if err := s.srv.waitForCompactions(s.srv.cfg.CompactionPostponeTimeout); err != nil {
+			logger.Error("Snapshot postponed", "error", err)
+			return err
+		}
+	}
+	op, err := s.srv.operations.begin(operationBackup, args.Name)
+	if err != nil {
+		return err
+	}
+	defer s.srv.operations.end(op)
+	log.Println("Creating snapshot", "path", s.srv.casConfig().BackupPath+"/"+args.Name)
+	info, cluster, err := s.srv.readyNode()
+	if err != nil {
+		return err
+	}
+	if err := s.srv.preflightSnapshot(); err != nil {
+		logger.Error("Snapshot preflight failed", "error", err)
+		return err
+	}
+	// schema is captured first so it covers every table in the snapshot
+	schema, err := s.srv.cql.Schema()
+	if err != nil {
+		logger.Error("Failed to read schema", "error", err)
+		return err
+	}
+	schemaModel, err := json.Marshal(schema)
+	if err != nil {
+		return err
+	}
+	snapshot, err := s.srv.nodetool().Snapshot(args.Name, nil, nil)
 	if err != nil {
 		logger.Error("Nodetool error", "error", err)
 		return err
 	}
+	logger.Info("Snapshot created", "name", snapshot.Name, "directory", snapshot.Path, "keyspaces", snapshot.Keyspaces)
 
 	// s3 path is /configured_path_prefix/cluster_name/host_id/backup_name
-	path := filepath.Join(s.srv.cfg.S3Path, strings.Replace(s.srv.cascluster.Name, " ", "_-_", -1), s.srv.casinfo.ID, args.Name)
+	path := filepath.Join(s.srv.cfg.S3Path, strings.Replace(cluster.Name, " ", "_-_", -1), info.ID, args.Name)
 
-	manifest, err := datastore.NewManifest(s.srv.cascfg.DataPath, args.Name, path)
+	manifest, err := datastore.NewManifest(s.srv.casConfig().DataPaths, args.Name, path)
 	if err != nil {
 		return err
 	}
+	manifest.Attach(datastore.SchemaFile, []byte(schema.CQL()))
+	manifest.Attach(datastore.SchemaModelFile, schemaModel)
+	if args.Roles {
+		roles, err := s.srv.cql.Roles(args.RolePasswords)
+		if err != nil {
+			logger.Error("Failed to read roles", "error", err)
+			return err
+		}
+		rolesModel, err := json.Marshal(roles)
+		if err != nil {
+			return err
+		}
+		manifest.Attach(datastore.RolesFile, rolesModel)
+		manifest.Attach(datastore.RolesCQLFile, []byte(cqlsh.RolesCQL(roles)))
+	}
 
 	logger.Info("Putting manifest into store", "manifest", manifest)
 	if err = s.srv.store.Put(manifest); err != nil {
 		return err
 	}
+	// hardlinked snapshots keep compacted sstables on disk
+	if err := s.srv.clearUploadedSnapshots(args.Name); err != nil {
+		logger.Warn("Failed to clear uploaded snapshots", "error", err)
+	}
+	reply.Name = manifest.Name
+	reply.Path = s.srv.store.ManifestPath(manifest.Name)
+	reply.Size = int(manifest.Size)
+	reply.Directory = snapshot.Path
+	reply.Keyspaces = snapshot.Keyspaces
+	return nil
+}
+
+// Local is the RPC endpoint for listing the snapshots on the node's disks
+func (s *Snapshots) Local(args *structs.SnapshotsLocalRequest, reply *structs.SnapshotsLocalReply) error {
+	logger := s.srv.logger(args)
+	snapshots, err := s.srv.localSnapshots()
+	if err != nil {
+		logger.Error("Failed to list snapshots", "error", err)
+		return err
+	}
+	reply.Snapshots = snapshots
+	return nil
+}
 
+// Purge is the RPC endpoint for clearing local snapshots older than a threshold
+func (s *Snapshots) Purge(args *structs.SnapshotsPurgeRequest, reply *structs.SnapshotsPurgeReply) error {
+	logger := s.srv.logger(args)
+	if err := args.Validate(); err != nil {
+		logger.Error("Snapshots.Purge Validation failed", "error", err)
+		return err
+	}
+	olderThan, _ := time.ParseDuration(args.OlderThan)
+	purged, err := s.srv.purgeSnapshots(olderThan, args.DryRun)
+	reply.Purged = purged
+	if err != nil {
+		logger.Error("Failed to purge snapshots", "error", err)
+		return err
+	}
 	return nil
 }
 
+// groupSnapshots groups table snapshots by snapshot name, sorted by name.
+func groupSnapshots(details []*nodetool.SnapshotDetails) []*structs.LocalSnapshot {
+	byName := make(map[string]*structs.LocalSnapshot)
+	snapshots := make([]*structs.LocalSnapshot, 0)
+	for _, d := range details {
+		snapshot, ok := byName[d.Name]
+		if !ok {
+			snapshot = &structs.LocalSnapshot{Name: d.Name, Tables: make([]*nodetool.SnapshotDetails, 0)}
+			byName[d.Name] = snapshot
+			snapshots = append(snapshots, snapshot)
+		}
+		snapshot.TrueSize += d.TrueSize
+		snapshot.SizeOnDisk += d.SizeOnDisk
+		snapshot.Tables = append(snapshot.Tables, d)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name < snapshots[j].Name })
+	return snapshots
+}
+
 func (s *Snapshots) Restore(args *structs.SnapshotsRestoreRequest, reply *structs.SnapshotsRestoreReply) error {
 	logger := s.srv.logger(args)
 
@@ -54,20 +158,161 @@ func (s *Snapshots) Restore(args *structs.SnapshotsRestoreRequest, reply *struct
 		logger.Error("Snapshots.Restore Validation failed", "error", err)
 		return err
 	}
-	if err := s.srv.cas.Stop(); err != nil {
+	if args.Path == "" {
+		args.Path = s.srv.store.ManifestPath(args.Name)
+	}
+	reply.ManifestPath = args.Path
+	op, err := s.srv.operations.begin(operationRestore, args.Path)
+	if err != nil {
+		return err
+	}
+	defer s.srv.operations.end(op)
+	// checked before the schema is touched, again when cassandra is stopped
+	if err := s.srv.operations.idle(args.Force, op); err != nil {
+		return err
+	}
+	if err := s.srv.preflightRestore(args.Path, args.Keyspaces); err != nil {
+		logger.Error("Restore preflight failed", "error", err)
+		return err
+	}
+	// schema has to be in place while cassandra is still running so the table
+	// directories exist before data is placed.
+	if args.CreateSchema {
+		if err := s.createSchema(args.Path, args.Replication); err != nil {
+			logger.Error("Failed to create schema", "error", err)
+			return err
+		}
+	}
+	// table directories are named after the live table ids, they are read
+	// before cassandra is stopped.
+	schema, err := s.srv.cql.Schema()
+	if err != nil {
+		logger.Error("Failed to read schema", "error", err)
+		return err
+	}
+	if err := s.srv.operations.stop(args.Force, op, s.srv.stopCassandra); err != nil {
 		logger.Error("Failed to stop cassandra", "error", err)
 		return err
 	}
-	if err := s.srv.cas.ClearData(args.Keyspaces); err != nil {
-		logger.Error("Failed to clear cassandra data")
+	if err := s.srv.process().ClearData(args.Keyspaces); err != nil {
+		logger.Error("Failed to clear cassandra data", "error", err)
+		return err
+	}
+	// the commit log holds writes for every keyspace, it is only dropped when
+	// everything is restored.
+	if len(args.Keyspaces) == 0 {
+		if err := s.srv.process().ClearLogs(); err != nil {
+			logger.Error("Failed to clear commit logs", "error", err)
+			return err
+		}
+	} else {
+		logger.Warn("Keeping commit logs for a partial restore, unflushed writes to restored tables are replayed")
 	}
-	if err := s.srv.store.Get(args.Path); err != nil {
+	if err := s.srv.store.Get(args.Path, schema.TableIDs()); err != nil {
 		logger.Error("Failed to download backups", "error", err)
 		return err
 	}
 	if err := s.srv.setupCassandra(); err != nil {
 		return err
 	}
+	// the cql connection was opened before cassandra was stopped
+	s.srv.cql.Close()
+	if args.RestoreRoles {
+		if err := s.restoreRoles(args.Path, args.Roles); err != nil {
+			logger.Error("Failed to restore roles", "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreRoles reapplies the roles stored with the manifest at path.
+func (s *Snapshots) restoreRoles(path string, names []string) error {
+	d, err := s.srv.store.GetFile(path, datastore.RolesFile)
+	if err != nil {
+		return err
+	}
+	var roles []*cqlsh.Role
+	if err := json.Unmarshal(d, &roles); err != nil {
+		return err
+	}
+	roles = cqlsh.SelectRoles(roles, names)
+	s.srv.logger(nil).Info("Restoring roles", "path", path, "roles", len(roles))
+	for _, role := range roles {
+		if role.SaltedHash != "" {
+			s.srv.logger(nil).Warn("Writing password hash into system_auth.roles", "role", role.Name)
+		}
+	}
+	return s.srv.cql.Exec(cqlsh.RoleStatements(roles))
+}
+
+// createSchema applies the schema stored with the manifest at path, creating
+// only missing keyspaces and tables, and waits for schema agreement.
+func (s *Snapshots) createSchema(path string, replication map[string]int) error {
+	logger := s.srv.logger(nil)
+	schema, err := s.srv.store.GetFile(path, datastore.SchemaFile)
+	if err != nil {
+		return err
+	}
+	stmts := cqlsh.PrepareSchema(string(schema), replication)
+	logger.Info("Creating schema", "path", path, "statements", len(stmts))
+	if err := s.srv.cql.Exec(stmts); err != nil {
+		return err
+	}
+	return s.srv.waitForSchemaAgreement(s.srv.cfg.SchemaAgreementTimeout)
+}
+
+// Schema is the RPC endpoint for reading the schema stored with a snapshot
+func (s *Snapshots) Schema(args *structs.SnapshotsSchemaRequest, reply *structs.SnapshotsSchemaReply) error {
+	logger := s.srv.logger(args)
+
+	if err := args.Validate(); err != nil {
+		logger.Error("Snapshots.Schema Validation failed", "error", err)
+		return err
+	}
+	path := args.Path
+	if path == "" {
+		path = s.srv.store.ManifestPath(args.Name)
+	}
+	schema, err := s.srv.store.GetFile(path, datastore.SchemaFile)
+	if err != nil {
+		logger.Error("Failed to read schema", "path", path, "error", err)
+		return err
+	}
+	reply.Name = args.Name
+	reply.Schema = string(schema)
+	return nil
+}
+
+// SchemaDiff is the RPC endpoint for comparing the schema stored with a snapshot
+// to the live schema
+func (s *Snapshots) SchemaDiff(args *structs.SnapshotsSchemaRequest, reply *structs.SnapshotsSchemaDiffReply) error {
+	logger := s.srv.logger(args)
+
+	if err := args.Validate(); err != nil {
+		logger.Error("Snapshots.SchemaDiff Validation failed", "error", err)
+		return err
+	}
+	path := args.Path
+	if path == "" {
+		path = s.srv.store.ManifestPath(args.Name)
+	}
+	d, err := s.srv.store.GetFile(path, datastore.SchemaModelFile)
+	if err != nil {
+		logger.Error("Failed to read schema", "path", path, "error", err)
+		return err
+	}
+	var backup cqlsh.Schema
+	if err := json.Unmarshal(d, &backup); err != nil {
+		return err
+	}
+	live, err := s.srv.cql.Schema()
+	if err != nil {
+		logger.Error("Failed to read live schema", "error", err)
+		return err
+	}
+	reply.Name = args.Name
+	reply.Diff = cqlsh.DiffSchema(&backup, live)
 	return nil
 }
 
@@ -0,0 +1,136 @@
+package buddy
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/Nomon/cassandra-buddy/buddy/cqlsh"
+	"github.com/Nomon/cassandra-buddy/buddy/nodetool"
+	"github.com/Nomon/cassandra-buddy/datastore"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+func TestGroupSnapshots(t *testing.T) {
+	snapshots := groupSnapshots([]*nodetool.SnapshotDetails{
+		{Name: "20160415120000", Keyspace: "app", Table: "events", TrueSize: 10, SizeOnDisk: 20},
+		{Name: "20160414120000", Keyspace: "app", Table: "events", TrueSize: 1, SizeOnDisk: 2},
+		{Name: "20160415120000", Keyspace: "app", Table: "users", TrueSize: 5, SizeOnDisk: 5},
+	})
+	if len(snapshots) != 2 || snapshots[0].Name != "20160414120000" || snapshots[1].Name != "20160415120000" {
+		t.Fatalf("unexpected snapshots %+v", snapshots)
+	}
+	if s := snapshots[1]; len(s.Tables) != 2 || s.TrueSize != 15 || s.SizeOnDisk != 25 {
+		t.Fatalf("unexpected snapshot %+v", s)
+	}
+}
+
+// rolesStore serves the roles stored with a manifest.
+type rolesStore struct {
+	datastore.Store
+	roles []byte
+}
+
+func (s *rolesStore) GetFile(p, file string) ([]byte, error) {
+	return s.roles, nil
+}
+
+// fakeCqlNode accepts native protocol connections without authentication and
+// records the statements queried on them.
+type fakeCqlNode struct {
+	net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+	stmts []string
+}
+
+func (n *fakeCqlNode) serve() {
+	for {
+		conn, err := n.Accept()
+		if err != nil {
+			return
+		}
+		n.mu.Lock()
+		n.conns = append(n.conns, conn)
+		n.mu.Unlock()
+		go n.handle(conn)
+	}
+}
+
+func (n *fakeCqlNode) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		header := make([]byte, 9)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header[5:]))
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		// READY to STARTUP, a void RESULT to QUERY
+		reply := []byte{0x80 | header[0], 0, header[2], header[3], 0x02, 0, 0, 0, 0}
+		if header[4] == 0x07 {
+			n.mu.Lock()
+			n.stmts = append(n.stmts, string(body[4:4+binary.BigEndian.Uint32(body)]))
+			n.mu.Unlock()
+			reply = append(reply, 0, 0, 0, 1)
+			reply[4] = 0x08
+			reply[8] = 4
+		}
+		if _, err := conn.Write(reply); err != nil {
+			return
+		}
+	}
+}
+
+// restart drops the open connections like a restarted cassandra.
+func (n *fakeCqlNode) restart() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, conn := range n.conns {
+		conn.Close()
+	}
+	n.conns = nil
+	n.stmts = nil
+}
+
+func TestRestoreRolesAfterRestart(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := &fakeCqlNode{Listener: l}
+	defer node.Close()
+	go node.serve()
+
+	cfg := cqlsh.DefaultConfig()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	cfg.Hosts = []string{host}
+	cfg.Port, _ = strconv.Atoi(port)
+	cql := cqlsh.NeqCql(cfg)
+	defer cql.Close()
+	roles, _ := json.Marshal([]*cqlsh.Role{{Name: "app", Login: true}})
+	srv := &Server{cql: cql, store: &rolesStore{roles: roles}, log: log15.New()}
+	s := &Snapshots{srv: srv}
+
+	// the connection is opened while cassandra runs, before the restore
+	if _, err := cql.Query("SELECT * FROM system_schema.tables"); err != nil {
+		t.Fatal(err)
+	}
+	node.restart()
+	if err := s.restoreRoles("/backups/20160415120000/manifest.json", nil); err != nil {
+		t.Fatalf("roles were not restored after the restart: %v", err)
+	}
+	want := []string{"CREATE ROLE IF NOT EXISTS app WITH LOGIN = true AND SUPERUSER = false"}
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	if !reflect.DeepEqual(node.stmts, want) {
+		t.Fatalf("unexpected statements %q", node.stmts)
+	}
+}
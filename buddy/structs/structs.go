@@ -2,7 +2,13 @@ package structs
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"time"
 
+	"github.com/Nomon/cassandra-buddy/buddy/cassandra"
+	"github.com/Nomon/cassandra-buddy/buddy/cqlsh"
+	"github.com/Nomon/cassandra-buddy/buddy/nodetool"
 	"golang.org/x/net/context"
 )
 
@@ -11,6 +17,13 @@ type RequestContext context.Context
 type SnapshotsCreateRequest struct {
 	RequestContext `json:"-"`
 	Name           string
+	// Roles exports roles, role hierarchy and grants with the snapshot.
+	Roles bool
+	// RolePasswords also exports the password hashes of the roles, they are
+	// stored in the snapshot as plain JSON.
+	RolePasswords bool
+	// Force snapshots without waiting for pending compactions.
+	Force bool
 }
 
 type SnapshotsRestoreRequest struct {
@@ -19,22 +32,191 @@ type SnapshotsRestoreRequest struct {
 	Path           string
 	Keyspaces      []string
 	Tables         []string
+	// CreateSchema applies the schema stored with the snapshot before restoring,
+	// only missing keyspaces and tables are created.
+	CreateSchema bool
+	// Replication overrides keyspace replication with a replication factor per
+	// datacenter when the schema is created.
+	Replication map[string]int
+	// RestoreRoles reapplies the roles exported with the snapshot once cassandra
+	// is back up, only Roles and the roles they are members of when Roles is set.
+	// Exported password hashes are written straight into system_auth.roles.
+	RestoreRoles bool
+	Roles        []string
+	// Force restores even when other backups or restores are running.
+	Force bool
+}
+
+type SnapshotsSchemaRequest struct {
+	RequestContext `json:"-"`
+	Name           string
+	Path           string
+}
+
+type SnapshotsLocalRequest struct {
+	RequestContext `json:"-"`
+}
+
+type SnapshotsPurgeRequest struct {
+	RequestContext `json:"-"`
+	// OlderThan is a duration like 72h, local snapshots taken before it are
+	// cleared.
+	OlderThan string
+	// DryRun reports the snapshots that would be cleared.
+	DryRun bool
 }
 
 type CassandraStartRequest struct {
 	RequestContext `json:"-"`
+	// Wait for the node to be up and normal before replying.
+	Wait bool
+	// Force starts cassandra even when a restore is running.
+	Force bool
+}
+
+type CassandraStartReply struct {
+	Process *cassandra.ProcessState `json:"process"`
 }
 
 type CassandraStopRequest struct {
+	RequestContext `json:"-"`
+	// Force stops cassandra even when backups or restores are running.
+	Force bool
 }
 
-type CassandraStartReply struct {
+type CassandraStopReply struct {
+	Process *cassandra.ProcessState `json:"process"`
+}
+
+type CassandraRestartRequest struct {
+	RequestContext `json:"-"`
+	Wait           bool
+	Force          bool
+}
+
+type CassandraRestartReply struct {
+	Process *cassandra.ProcessState `json:"process"`
+}
+
+type CassandraStatusRequest struct {
+	RequestContext `json:"-"`
+}
+
+type CassandraStatusReply struct {
+	Process   *cassandra.ProcessState `json:"process"`
+	Readiness string                  `json:"readiness"`
+	HostID    string                  `json:"host_id,omitempty"`
+	Cluster   string                  `json:"cluster,omitempty"`
+	// Operations are the backups and restores in progress.
+	Operations []*Operation `json:"operations"`
+}
+
+// Operation is a backup or restore in progress.
+type Operation struct {
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type CassandraProcessRequest struct {
+	RequestContext `json:"-"`
+}
+
+type CassandraLogsRequest struct {
+	RequestContext `json:"-"`
+	// Tail is the number of lines to return, all kept lines when 0.
+	Tail int
+}
+
+type CassandraLogsReply struct {
+	Lines []string `json:"lines"`
+}
+
+type CassandraReplaceRequest struct {
+	RequestContext `json:"-"`
+	// Address of the dead node this node replaces.
+	Address string
+	// ClearData removes the data, commit logs and saved caches of this node
+	// before the replacement, a node with any of them is refused otherwise.
+	ClearData bool
+	// Force replaces even when backups or restores are running.
+	Force bool
+}
+
+func (c *CassandraReplaceRequest) Validate() error {
+	if net.ParseIP(c.Address) == nil {
+		return errors.New("Address must be the ip address of the node to replace")
+	}
+	return nil
+}
+
+type CassandraReplacementRequest struct {
+	RequestContext `json:"-"`
+}
+
+type CassandraReplaceReply struct {
+	Replacement *Replacement `json:"replacement"`
+}
+
+// Replacement is the progress of replacing a dead node.
+type Replacement struct {
+	Address string `json:"address"`
+	// State is bootstrapping, done or failed.
+	State      string    `json:"state"`
+	Mode       string    `json:"mode,omitempty"`
+	Received   int64     `json:"received_bytes"`
+	Total      int64     `json:"total_bytes"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type CassandraHealthRequest struct {
+	RequestContext `json:"-"`
+}
+
+type CassandraHealthReply struct {
+	Healthy bool `json:"healthy"`
+	// Problems are the reasons the node is not healthy.
+	Problems    []string                  `json:"problems"`
+	Readiness   string                    `json:"readiness"`
+	Mode        string                    `json:"mode"`
+	ThreadPools []*nodetool.ThreadPool    `json:"thread_pools"`
+	Dropped     map[string]int64          `json:"dropped"`
+	Compactions *nodetool.CompactionStats `json:"compactions"`
+}
+
+type CassandraProcessReply struct {
+	Process *cassandra.ProcessState `json:"process"`
+	// Readiness is the startup phase of the node, ready once it is up and normal.
+	Readiness string `json:"readiness"`
 }
 
 type SnapshotsCreateReply struct {
 	Name string
 	Path string
 	Size int
+	// Directory is the snapshot directory nodetool created under each table.
+	Directory string
+	// Keyspaces that were snapshotted, empty when all were.
+	Keyspaces []string
+}
+
+// LocalSnapshot is a snapshot still on the node's disks.
+type LocalSnapshot struct {
+	Name       string                      `json:"name"`
+	CreatedAt  time.Time                   `json:"created_at"`
+	TrueSize   int64                       `json:"true_size"`
+	SizeOnDisk int64                       `json:"size_on_disk"`
+	Tables     []*nodetool.SnapshotDetails `json:"tables"`
+}
+
+type SnapshotsLocalReply struct {
+	Snapshots []*LocalSnapshot `json:"snapshots"`
+}
+
+type SnapshotsPurgeReply struct {
+	Purged []*LocalSnapshot `json:"purged"`
 }
 
 type SnapshotsRestoreReply struct {
@@ -42,9 +224,37 @@ type SnapshotsRestoreReply struct {
 	ManifestPath   string `json:"manifest_path"`
 }
 
+type SnapshotsSchemaReply struct {
+	Name   string
+	Schema string
+}
+
+type SnapshotsSchemaDiffReply struct {
+	Name string
+	Diff *cqlsh.SchemaDiff
+}
+
 func (s *SnapshotsRestoreRequest) Validate() error {
 	if s.Path == "" && s.Name == "" {
 		return errors.New("Snapshot requires path or name to be set")
 	}
 	return nil
 }
+
+func (s *SnapshotsPurgeRequest) Validate() error {
+	d, err := time.ParseDuration(s.OlderThan)
+	if err != nil {
+		return fmt.Errorf("Invalid OlderThan %q: %v", s.OlderThan, err)
+	}
+	if d <= 0 {
+		return errors.New("Purge requires OlderThan to be positive")
+	}
+	return nil
+}
+
+func (s *SnapshotsSchemaRequest) Validate() error {
+	if s.Path == "" && s.Name == "" {
+		return errors.New("Schema requires path or name to be set")
+	}
+	return nil
+}
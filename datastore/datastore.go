@@ -2,5 +2,14 @@ package datastore
 
 type Store interface {
 	Put(m *Manifest) error
-	Get(path string) error
+	// Get downloads the snapshot at path into the table directories named by ids.
+	Get(path string, ids TableIDs) error
+	// Manifest reads the manifest at path without downloading the snapshot.
+	Manifest(path string) (*Manifest, error)
+	// ManifestPath returns the path of the manifest for snapshot name.
+	ManifestPath(name string) string
+	// Uploaded reports whether the manifest for snapshot name is in the store.
+	Uploaded(name string) (bool, error)
+	// GetFile reads a file attached to the manifest at path.
+	GetFile(path, file string) ([]byte, error)
 }
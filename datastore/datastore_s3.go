@@ -2,11 +2,13 @@ package datastore
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/mitchellh/goamz/aws"
@@ -17,14 +19,14 @@ type s3Store struct {
 	bucket      string
 	region      string
 	base        string
-	dataPath    string
+	dataPaths   []string
 	maxParallel int
 	auth        *aws.Auth
 	s3bucket    *s3.Bucket
 }
 
 type S3Cfg struct {
-	DataPath    string
+	DataPaths   []string
 	Bucket      string
 	BasePath    string
 	Region      string
@@ -42,7 +44,7 @@ func NewS3(cfg *S3Cfg) Store {
 		bucket:      cfg.Bucket,
 		region:      cfg.Region,
 		base:        cfg.BasePath,
-		dataPath:    cfg.DataPath,
+		dataPaths:   cfg.DataPaths,
 		s3bucket:    bucket,
 		auth:        &auth,
 		maxParallel: 20,
@@ -51,11 +53,24 @@ func NewS3(cfg *S3Cfg) Store {
 
 func (s *s3Store) Put(m *Manifest) error {
 	var size int64
+	var mu sync.Mutex
+	var uploadErr error
 	sem := make(chan bool, s.maxParallel)
 	var wg sync.WaitGroup
-
+	// a table has a directory on every data disk, their files are merged under
+	// one path and balanced across the disks again on restore.
+	paths := make(map[string]bool)
+	// every directory is read before the first upload starts so an error does
+	// not leave uploads running.
+	type upload struct {
+		src, dst string
+	}
+	uploads := make([]upload, 0)
 	for _, dir := range m.Directories {
-		path := s.getS3Path(m.Name, dir)
+		path, err := s.getS3Path(m.Name, dir)
+		if err != nil {
+			return err
+		}
 		files, err := ioutil.ReadDir(dir)
 		if err != nil {
 			return err
@@ -66,34 +81,59 @@ func (s *s3Store) Put(m *Manifest) error {
 			return err
 		}
 
-		m.Paths = append(m.Paths, relPath)
+		if !paths[relPath] {
+			paths[relPath] = true
+			m.Paths = append(m.Paths, relPath)
+		}
 		for _, file := range files {
-			wg.Add(1)
-			go func(src, dst string) {
-				defer wg.Done() // complete wg
-				defer func() {
-					<-sem // decrease max parallel semaphore
-				}()
-				// aquire semaphore
-				sem <- true
-				upSize, err := s.putFile(dst, src)
-				if err != nil {
-					log.Println(err)
-					return
+			uploads = append(uploads, upload{filepath.Join(dir, file.Name()), filepath.Join(path, file.Name())})
+		}
+	}
+	for _, u := range uploads {
+		wg.Add(1)
+		go func(src, dst string) {
+			defer wg.Done() // complete wg
+			defer func() {
+				<-sem // decrease max parallel semaphore
+			}()
+			// aquire semaphore
+			sem <- true
+			upSize, err := s.putFile(dst, src)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Println(err)
+				if uploadErr == nil {
+					uploadErr = fmt.Errorf("Failed to upload %s: %v", src, err)
 				}
-				size += upSize
-				log.Println("File uploaded", src, dst)
-			}(filepath.Join(dir, file.Name()), filepath.Join(path, file.Name()))
+				return
+			}
+			size += upSize
+			log.Println("File uploaded", src, dst)
+		}(u.src, u.dst)
+	}
+	wg.Wait()
+	// without the manifest a partial upload can not be restored
+	if uploadErr != nil {
+		return uploadErr
+	}
+	for file, data := range m.attachments {
+		p := filepath.Join(s.base, m.Name, file)
+		log.Println("uploading", file, "to", p)
+		if err := s.s3bucket.Put(p, data, "text/plain", s3.Private); err != nil {
+			return err
 		}
 	}
+	m.Size = size
 	md, err := json.Marshal(m)
 	if err != nil {
 		return err
 	}
-	wg.Wait()
-	p := filepath.Join(s.base, m.Name, "manifest.json")
+	p := s.ManifestPath(m.Name)
 	log.Println("uploading manifest to", p)
-	s.s3bucket.Put(p, md, "application/json", s3.Private)
+	if err := s.s3bucket.Put(p, md, "application/json", s3.Private); err != nil {
+		return err
+	}
 	log.Println("Snapshot uploaded, size:", size)
 	return nil
 }
@@ -113,7 +153,7 @@ func (s *s3Store) putFile(dst, src string) (int64, error) {
 	return stat.Size(), s.s3bucket.PutReader(dst, f, stat.Size(), "application/octet-stream", s3.Private)
 }
 
-func (s *s3Store) Get(p string) error {
+func (s *s3Store) Get(p string, ids TableIDs) error {
 	reader, err := s.getFile(p)
 	if err != nil {
 		return err
@@ -124,68 +164,179 @@ func (s *s3Store) Get(p string) error {
 		return err
 	}
 	m.Path = filepath.Dir(p)
-	err = s.downloadManifest(&m)
+	err = s.downloadManifest(&m, ids)
 	return err
 }
 
+// Manifest reads the manifest at p, manifests uploaded before sizes were
+// recorded are sized from the listing of their paths.
+func (s *s3Store) Manifest(p string) (*Manifest, error) {
+	reader, err := s.getFile(p)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	var m Manifest
+	if err := json.NewDecoder(reader).Decode(&m); err != nil {
+		return nil, err
+	}
+	m.Path = filepath.Dir(p)
+	if m.Size == 0 {
+		for _, dir := range m.Paths {
+			keys, err := s.listKeys(strings.TrimPrefix(filepath.Join(m.Path, dir), "/") + "/")
+			if err != nil {
+				return nil, err
+			}
+			for _, k := range keys {
+				m.Size += k.Size
+			}
+		}
+	}
+	return &m, nil
+}
+
+// listKeys lists every key directly under prefix, s3 returns at most 1000 keys
+// per listing so it is paged with markers. Every listing of the store goes
+// through it.
+func (s *s3Store) listKeys(prefix string) ([]s3.Key, error) {
+	keys := make([]s3.Key, 0)
+	marker := ""
+	for {
+		res, err := s.s3bucket.List(prefix, "/", marker, 1000)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, res.Contents...)
+		if !res.IsTruncated {
+			return keys, nil
+		}
+		// NextMarker is only returned with a delimiter, the last key continues
+		// the listing otherwise
+		marker = res.NextMarker
+		if marker == "" && len(res.Contents) > 0 {
+			marker = res.Contents[len(res.Contents)-1].Key
+		}
+		if marker == "" {
+			return keys, nil
+		}
+	}
+}
+
+func (s *s3Store) ManifestPath(name string) string {
+	return filepath.Join(s.base, name, "manifest.json")
+}
+
+// Uploaded reports whether the manifest of snapshot name is in the bucket,
+// keys that only start with the manifest path do not count.
+func (s *s3Store) Uploaded(name string) (bool, error) {
+	manifest := strings.TrimPrefix(s.ManifestPath(name), "/")
+	keys, err := s.listKeys(manifest)
+	if err != nil {
+		return false, err
+	}
+	for _, k := range keys {
+		if k.Key == manifest {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *s3Store) GetFile(p, file string) ([]byte, error) {
+	reader, err := s.getFile(filepath.Join(filepath.Dir(p), file))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
 func (s *s3Store) getFile(path string) (io.ReadCloser, error) {
 	return s.s3bucket.GetReader(path)
 }
 
-func (s *s3Store) downloadDirectory(src, dst string) error {
+// downloadDirectory downloads the files under s3 path src into the table
+// directory dst, dst is relative to the data directories. Each sstable is placed
+// whole on the data directory picked by disks.
+func (s *s3Store) downloadDirectory(src, dst string, disks *diskBalancer) error {
 	src = src[1:]
-	log.Println("Creating folder", dst)
-	if err := os.MkdirAll(dst, os.ModePerm); err != nil {
-		return err
-	}
 	log.Println("Download s3 path", src, "into", dst)
-	res, err := s.s3bucket.List(src+"/", "/", "", 1000)
+	keys, err := s.listKeys(src + "/")
 	if err != nil {
 		return err
 	}
-	for _, k := range res.Contents {
-		log.Println("Opening reader to ", k.Key)
-		// wrap in a function scope to close files
-		err := func(key string) error {
-			reader, err := s.getFile(key)
-			if err != nil {
-				return err
-			}
-			log.Println("creating file", filepath.Join(dst, filepath.Base(key)))
-			f, err := os.Create(filepath.Join(dst, filepath.Base(key)))
-			if err != nil {
-				return err
-			}
-			_, err = io.Copy(f, reader)
-			if err != nil && err != io.EOF {
+	sstables := make(map[string][]s3.Key)
+	generations := make([]string, 0)
+	for _, k := range keys {
+		gen := sstableGeneration(filepath.Base(k.Key))
+		if _, ok := sstables[gen]; !ok {
+			generations = append(generations, gen)
+		}
+		sstables[gen] = append(sstables[gen], k)
+	}
+	for _, gen := range generations {
+		var size int64
+		for _, k := range sstables[gen] {
+			size += k.Size
+		}
+		dir := filepath.Join(disks.Place(size), dst)
+		log.Println("Creating folder", dir)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+		for _, k := range sstables[gen] {
+			if err := s.downloadFile(k.Key, filepath.Join(dir, filepath.Base(k.Key))); err != nil {
 				return err
 			}
-			defer f.Close()
-			defer reader.Close()
-			return nil
-		}(k.Key)
-		if err != nil {
-			return err
 		}
 	}
 	return nil
 }
 
-func (s *s3Store) downloadManifest(m *Manifest) error {
+func (s *s3Store) downloadFile(key, dst string) error {
+	log.Println("Opening reader to ", key)
+	reader, err := s.getFile(key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	log.Println("creating file", dst)
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, reader)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func (s *s3Store) downloadManifest(m *Manifest, ids TableIDs) error {
 	log.Println("downloadManifest", m)
 	errc := make(chan error, len(m.Paths))
 	defer close(errc)
 	var wg sync.WaitGroup
-	for _, dir := range m.Paths {
+	tables := newTableMapper(ids)
+	disks := newDiskBalancer(s.dataPaths)
+	livePaths := make([]string, len(m.Paths))
+	for i, dir := range m.Paths {
+		live, err := tables.Map(dir)
+		if err != nil {
+			return err
+		}
+		livePaths[i] = live
+	}
+	for i, dir := range m.Paths {
 		s3path := filepath.Join(m.Path, dir)
-		fsPath := filepath.Join(s.dataPath, dir)
 		wg.Add(1)
 		go func(src, dst string, ec chan error) {
 			defer wg.Done()
-			if err := s.downloadDirectory(src, dst); err != nil {
+			if err := s.downloadDirectory(src, dst, disks); err != nil {
 				ec <- err
 			}
-		}(s3path, fsPath, errc)
+		}(s3path, livePaths[i], errc)
 	}
 	wg.Wait()
 	if len(errc) > 0 {
@@ -195,21 +346,28 @@ func (s *s3Store) downloadManifest(m *Manifest) error {
 	return nil
 }
 
-func (s *s3Store) getLocalPath(name, dir string) string {
-	rel, err := filepath.Rel(s.dataPath, dir)
-	if err != nil {
-		panic(err)
+// dataDir returns the data directory dir is under.
+func (s *s3Store) dataDir(dir string) (string, error) {
+	for _, dataPath := range s.dataPaths {
+		rel, err := filepath.Rel(dataPath, dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return dataPath, nil
+		}
 	}
-	return rel
+	return "", fmt.Errorf("%s is not under a data directory", dir)
 }
 
-func (s *s3Store) getS3Path(name, dir string) string {
-	rel, err := filepath.Rel(s.dataPath, dir)
+func (s *s3Store) getS3Path(name, dir string) (string, error) {
+	dataPath, err := s.dataDir(dir)
 	if err != nil {
-		panic(err)
+		return "", err
+	}
+	rel, err := filepath.Rel(dataPath, dir)
+	if err != nil {
+		return "", err
 	}
 	rel = filepath.Dir(rel)
 	rel = filepath.Dir(rel)
 	log.Println(rel, dir)
-	return filepath.Join(s.base, name, rel)
+	return filepath.Join(s.base, name, rel), nil
 }
@@ -0,0 +1,47 @@
+package datastore
+
+import (
+	"strings"
+	"sync"
+)
+
+// sstableGeneration returns the part of an sstable component file name all
+// components of the sstable share, mc-12-big-Data.db becomes mc-12-big.
+func sstableGeneration(file string) string {
+	i := strings.LastIndex(file, "-")
+	if i < 0 {
+		return file
+	}
+	return file[:i]
+}
+
+// diskBalancer spreads restored sstables over the data directories, a snapshot
+// does not record which disk an sstable came from and the node restored into
+// may have a different number of disks.
+type diskBalancer struct {
+	mu        sync.Mutex
+	dataPaths []string
+	placed    []int64
+}
+
+func newDiskBalancer(dataPaths []string) *diskBalancer {
+	return &diskBalancer{
+		dataPaths: dataPaths,
+		placed:    make([]int64, len(dataPaths)),
+	}
+}
+
+// Place returns the data directory with the fewest bytes placed so far and
+// accounts size to it.
+func (b *diskBalancer) Place(size int64) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	min := 0
+	for i := range b.placed {
+		if b.placed[i] < b.placed[min] {
+			min = i
+		}
+	}
+	b.placed[min] += size
+	return b.dataPaths[min]
+}
@@ -0,0 +1,31 @@
+package datastore
+
+import "testing"
+
+func TestSstableGeneration(t *testing.T) {
+	tests := map[string]string{
+		"mc-12-big-Data.db":      "mc-12-big",
+		"mc-12-big-TOC.txt":      "mc-12-big",
+		"ks-users-ka-3-Index.db": "ks-users-ka-3",
+		"manifest.json":          "manifest.json",
+	}
+	for in, want := range tests {
+		if got := sstableGeneration(in); got != want {
+			t.Fatalf("sstableGeneration(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestDiskBalancer(t *testing.T) {
+	b := newDiskBalancer([]string{"/data1", "/data2"})
+	placed := []string{b.Place(100), b.Place(10), b.Place(50), b.Place(50)}
+	want := []string{"/data1", "/data2", "/data2", "/data2"}
+	for i := range want {
+		if placed[i] != want[i] {
+			t.Fatalf("placement %d = %s, want %s", i, placed[i], want[i])
+		}
+	}
+	if got := b.Place(1); got != "/data1" {
+		t.Fatalf("expected /data1 after /data2 filled up, got %s", got)
+	}
+}
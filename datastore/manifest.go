@@ -19,30 +19,64 @@ type Manifest struct {
 	Directories []string `json:"-"`
 	Tables      []string `json:"-"`
 	Paths       []string `json:"paths"`
+	Files       []string `json:"files,omitempty"`
+	// Size is the bytes of sstables uploaded, restores need as much free space.
+	Size int64 `json:"size,omitempty"`
+	// attachments are stored next to the manifest, see Attach.
+	attachments map[string][]byte
 }
 
-// NewManifest creates a new manifest
-func NewManifest(baseDir, name, path string) (m *Manifest, err error) {
-	m = &Manifest{
+// Files stored next to the manifest
+const (
+	// SchemaFile is the cluster schema as CQL.
+	SchemaFile = "schema.cql"
+	// SchemaModelFile is the cluster schema as json for comparing schemas.
+	SchemaModelFile = "schema.json"
+	// RolesFile are the roles and grants as json for selective restores.
+	RolesFile = "roles.json"
+	// RolesCQLFile are the roles and grants as CQL.
+	RolesCQLFile = "roles.cql"
+)
+
+// NewManifest creates a new manifest from the snapshot directories in all data directories.
+func NewManifest(dataDirs []string, name, path string) (*Manifest, error) {
+	m := &Manifest{
 		Name:        name,
 		Path:        path,
+		Keyspaces:   make([]string, 0),
 		Directories: make([]string, 0),
 		Paths:       make([]string, 0),
 	}
-	if m.Keyspaces, err = readKeyspaces(baseDir); err != nil {
-		return
-	}
-	for _, ks := range m.Keyspaces {
-		dirs, err := readSnapshotDirs(baseDir, ks, name)
+	seen := make(map[string]bool)
+	for _, dataDir := range dataDirs {
+		keyspaces, err := readKeyspaces(dataDir)
 		if err != nil {
 			return nil, err
 		}
-		m.Directories = append(m.Directories, dirs...)
+		for _, ks := range keyspaces {
+			if !seen[ks] {
+				seen[ks] = true
+				m.Keyspaces = append(m.Keyspaces, ks)
+			}
+			dirs, err := readSnapshotDirs(dataDir, ks, name)
+			if err != nil {
+				return nil, err
+			}
+			m.Directories = append(m.Directories, dirs...)
+		}
 	}
-
 	return m, nil
 }
 
+// Attach adds a file to be stored next to the manifest.
+func (m *Manifest) Attach(file string, data []byte) {
+	if m.attachments == nil {
+		m.attachments = make(map[string][]byte)
+	}
+	m.attachments[file] = data
+	m.Files = append(m.Files, file)
+}
+
 // readSnapshotDirs will look for any subfolders under cassandra data folder that match the
 // backup name.
 func readSnapshotDirs(dir, keyspace, backupName string) ([]string, error) {
@@ -6,7 +6,7 @@ import (
 )
 
 func TestManifest(t *testing.T) {
-	m, err := NewManifest("/usr/local/var/lib/cassandra/data/", "1460628403086")
+	m, err := NewManifest([]string{"/usr/local/var/lib/cassandra/data/"}, "1460628403086", "/cassandra-backups/1460628403086")
 	if err != nil {
 		t.Fatal(err)
 
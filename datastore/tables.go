@@ -0,0 +1,59 @@
+package datastore
+
+import (
+	"log"
+	path "path/filepath"
+	"regexp"
+	"strings"
+)
+
+// tableDirRegex matches cassandra table directories, they are named <table>-<id>
+// where id is the table uuid without dashes.
+var tableDirRegex = regexp.MustCompile(`^(.+)-([0-9a-f]{32})$`)
+
+// splitTableDir splits a table directory name into table name and table id.
+func splitTableDir(dir string) (table, id string, ok bool) {
+	parts := tableDirRegex.FindStringSubmatch(dir)
+	if parts == nil {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// TableIDs maps keyspace.table to the id of the live table as found in
+// system_schema.tables.
+type TableIDs map[string]string
+
+// tableMapper remaps manifest paths (keyspace/table-id) to the directories the
+// tables currently live in, table ids change when a table is recreated or restored
+// into a fresh cluster and cassandra ignores files placed under the old id.
+// A dropped table leaves its directory behind, so the live directory is named
+// after the id in the schema rather than picked from the data directories.
+type tableMapper struct {
+	ids TableIDs
+}
+
+func newTableMapper(ids TableIDs) *tableMapper {
+	return &tableMapper{ids: ids}
+}
+
+// Map returns the live path for manifest path p, if the table is not in the
+// schema p is returned unchanged.
+func (tm *tableMapper) Map(p string) (string, error) {
+	keyspace, dir := path.Split(path.Clean(p))
+	keyspace = path.Clean(keyspace)
+	table, id, ok := splitTableDir(dir)
+	if !ok || keyspace == "." {
+		return p, nil
+	}
+	liveID, ok := tm.ids[keyspace+"."+table]
+	if !ok {
+		log.Println("No live table id for", keyspace, table, "using", dir)
+		return p, nil
+	}
+	liveID = strings.Replace(liveID, "-", "", -1)
+	if liveID != id {
+		log.Println("Remapping table", keyspace, table, "from", id, "to", liveID)
+	}
+	return path.Join(keyspace, table+"-"+liveID), nil
+}
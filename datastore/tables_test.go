@@ -0,0 +1,28 @@
+package datastore
+
+import (
+	"testing"
+)
+
+func TestTableMapper(t *testing.T) {
+	tm := newTableMapper(TableIDs{
+		"ks.users":  "5b1a7e20-f6a6-11e5-a8a4-c1d3a4c7e9a1",
+		"ks.events": "7c2d1e40-f6a6-11e5-a8a4-c1d3a4c7e9a1",
+	})
+	tests := map[string]string{
+		"ks/users-11111111111111111111111111111111":    "ks/users-5b1a7e20f6a611e5a8a4c1d3a4c7e9a1",
+		"ks/events-7c2d1e40f6a611e5a8a4c1d3a4c7e9a1":   "ks/events-7c2d1e40f6a611e5a8a4c1d3a4c7e9a1",
+		"ks/missing-11111111111111111111111111111111":  "ks/missing-11111111111111111111111111111111",
+		"other/users-11111111111111111111111111111111": "other/users-11111111111111111111111111111111",
+		"ks/legacy": "ks/legacy",
+	}
+	for in, want := range tests {
+		got, err := tm.Map(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("Map(%s) = %s, want %s", in, got, want)
+		}
+	}
+}